@@ -0,0 +1,202 @@
+package main
+
+import "github.com/veandco/go-sdl2/sdl"
+
+// ActionKind is something the reader can do, whatever key or click it
+// came from. Events are turned into actions by App.Actions and actions
+// change the state in App.Do, so everything in between can be tested
+// without a window.
+type ActionKind int
+
+const (
+	ActionNone ActionKind = iota
+	ActionQuit
+
+	ActionLineUp
+	ActionLineDown
+	ActionPageUp
+	ActionPageDown
+	ActionHalfPageUp
+	ActionHalfPageDown
+	ActionTop    // N is the line to go to, counted from 1, the first one without it
+	ActionBottom // the same, the last line without N
+	ActionScroll // Amount is how many notches the wheel turned, up is positive
+	ActionZoomIn
+	ActionZoomOut
+
+	ActionMouseMove // X and Y are in drawable pixels, where they are on the screen
+	ActionMouseDown
+	ActionMouseUp
+
+	ActionVim        // vim mode on or off, see vimAction
+	ActionWordNext   // the word cursor to the next word
+	ActionWordPrev   // and the one before
+	ActionLookup     // the word under the cursor, like clicking on it
+	ActionIgnoreWord // mark the word under the cursor ignored, or not anymore
+
+	ActionHighlight       // highlight the selection
+	ActionHighlightColor  // next color, for the next highlight and the current one
+	ActionNote            // write a note on the current highlight
+	ActionDeleteHighlight // delete the current highlight
+	ActionExport          // highlights and notes to <text>.highlights.md
+	ActionConcordance     // sentences with the word we clicked on in every text
+
+	ActionBookmark        // name a bookmark at the selection or the top of the page
+	ActionToggleBookmarks // the bookmark panel
+	ActionBookmarkUp
+	ActionBookmarkDown
+	ActionBookmarkGo // N is the bookmark, -1 for the one under the cursor
+	ActionBookmarkDelete
+	ActionClosePanels
+	ActionBack // close what's open, leave vim mode or quit, escape by default
+	ActionToggleHUD
+	ActionHelp // the keys and what they do
+
+	ActionSearch // open the search bar
+	ActionSearchNext
+	ActionSearchPrev
+	ActionSearchIgnoreCase
+	ActionSearchIgnoreDiacritics
+
+	ActionPromptInsert // Text is what was typed
+	ActionPromptBackspace
+	ActionPromptSubmit
+	ActionPromptCancel
+)
+
+// N is how many times, the count typed before a key in vim mode, and
+// -1 when there's none
+type Action struct {
+	Kind   ActionKind
+	X, Y   int
+	N      int
+	Amount float64
+	Text   string
+}
+
+// Actions is what event means right now, the same key does different
+// things while the prompt or the bookmark panel is open
+func (app *App) Actions(event sdl.Event) []Action {
+	switch t := event.(type) {
+	case *sdl.QuitEvent:
+		return []Action{{Kind: ActionQuit}}
+	case *sdl.TextInputEvent:
+		if app.prompt.IsOpen() {
+			return []Action{{Kind: ActionPromptInsert, Text: t.GetText()}}
+		}
+	case *sdl.MouseMotionEvent:
+		// everything on screen is in drawable pixels
+		x, y := app.scale.ToDrawable(t.X, t.Y)
+		return []Action{{Kind: ActionMouseMove, X: int(x), Y: int(y)}}
+	case *sdl.MouseWheelEvent:
+		return []Action{{Kind: ActionScroll, Amount: float64(t.Y)}}
+	case *sdl.MouseButtonEvent:
+		x, y := app.scale.ToDrawable(t.X, t.Y)
+		if panel := app.bookmarkRect(); app.showBookmarks && rectContains(panel, x, y) {
+			if t.Type == sdl.MOUSEBUTTONUP {
+				if i := overlayLineAt(panel, app.overlayFontSize(), y); i >= 0 && i < len(app.bookmarks) {
+					return []Action{{Kind: ActionBookmarkGo, N: i}}
+				}
+			}
+			return nil
+		}
+		switch {
+		case t.Type == sdl.MOUSEBUTTONDOWN && t.State == sdl.PRESSED:
+			return []Action{{Kind: ActionMouseDown, X: int(x), Y: int(y)}}
+		case t.Type == sdl.MOUSEBUTTONUP:
+			return []Action{{Kind: ActionMouseUp, X: int(x), Y: int(y)}}
+		}
+	case *sdl.KeyboardEvent:
+		if kind := app.keyAction(t); kind != ActionNone {
+			return []Action{{Kind: kind, N: app.takeCount()}}
+		}
+	}
+	return nil
+}
+
+func (app *App) keyAction(t *sdl.KeyboardEvent) ActionKind {
+	sym, mod := t.Keysym.Sym, t.Keysym.Mod
+
+	// while the prompt is open every key belongs to it
+	if app.prompt.IsOpen() {
+		app.count = 0
+		search := app.prompt.Kind == PromptSearch
+		switch {
+		case t.Type == sdl.KEYDOWN && sym == sdl.K_BACKSPACE:
+			return ActionPromptBackspace
+		case t.Type != sdl.KEYUP:
+		case sym == sdl.K_ESCAPE:
+			return ActionPromptCancel
+		case search && mod&sdl.KMOD_CTRL != 0 && sym == sdl.K_i:
+			return ActionSearchIgnoreCase
+		case search && mod&sdl.KMOD_CTRL != 0 && sym == sdl.K_d:
+			return ActionSearchIgnoreDiacritics
+		// the search bar stays open, enter goes to the next match and
+		// shift+enter to the previous one
+		case search && sym == sdl.K_RETURN && mod&sdl.KMOD_SHIFT != 0:
+			return ActionSearchPrev
+		case search && sym == sdl.K_RETURN:
+			return ActionSearchNext
+		case sym == sdl.K_RETURN:
+			return ActionPromptSubmit
+		}
+		return ActionNone
+	}
+
+	if app.isBack(sym, mod) {
+		if app.showBookmarks || app.showConcordance || app.showHelp {
+			if t.Type == sdl.KEYUP {
+				return ActionClosePanels
+			}
+			return ActionNone
+		}
+		// back gets out of vim mode before it quits
+		if app.vim {
+			if t.Type == sdl.KEYUP {
+				return ActionVim
+			}
+			return ActionNone
+		}
+		return ActionQuit
+	}
+
+	if t.Type != sdl.KEYUP {
+		return ActionNone
+	}
+
+	// the bookmark panel takes over the arrows and enter
+	if app.showBookmarks {
+		kind := ActionNone
+		switch sym {
+		case sdl.K_UP:
+			kind = ActionBookmarkUp
+		case sdl.K_DOWN:
+			kind = ActionBookmarkDown
+		case sdl.K_RETURN:
+			kind = ActionBookmarkGo
+		case sdl.K_DELETE:
+			kind = ActionBookmarkDelete
+		}
+		if kind != ActionNone {
+			app.count = 0 // N is the bookmark for these
+			return kind
+		}
+	}
+
+	if app.vim {
+		return app.vimAction(sym, mod)
+	}
+	return app.keys.Action(sym, mod)
+}
+
+// isBack is the key that's bound to ActionBack, vim mode can have one of
+// its own
+func (app *App) isBack(sym sdl.Keycode, mod uint16) bool {
+	combo := NewKeyCombo(sym, mod)
+	if app.vim {
+		if kind, ok := app.vimKeys[combo]; ok {
+			return kind == ActionBack
+		}
+	}
+	return app.keys[combo] == ActionBack
+}
@@ -0,0 +1,890 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/freetype"
+	"github.com/veandco/go-sdl2/sdl"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	zoomStep     float64 = 2 // points per zoom in or out
+	minFontSize  float64 = 6
+	maxFontSize  float64 = 96
+	zoomDuration         = 200 * time.Millisecond
+)
+
+// App is the reader without the window: the document, where we are in
+// it and what's on the page. Events become Actions, Do changes the state,
+// Update moves things along once a frame and Screen.Render draws it.
+type App struct {
+	doc     *Document
+	fam     *FontFamily
+	db      *bolt.DB
+	lem     Lemmatizer
+	textDir string // where the concordance looks for texts
+
+	running bool
+	clock   Clock
+
+	// the page is drawn into bg, everything but the text, that's queued
+	// in atlas
+	scale       DisplayScale
+	ctx         *freetype.Context
+	bg          *image.RGBA
+	damage      *Damage // what Render has to upload of bg
+	atlas       *GlyphAtlas
+	pageRects   []image.Rectangle // everything drawPage drew into bg
+	pageChanged bool              // set by drawPage, there's a new frame to draw
+	layoutTime  time.Duration     // what drawPage took since the last frame, for the HUD
+
+	fontSize   float64 // points
+	anims      *Animator
+	pageTop    int // the baseline of the first line
+	pageLeft   int // where the lines start, see Document.Left
+	startIndex int
+	numLines   int
+	jumpToLine int
+	redrawPage bool
+
+	// the page scrolls by pixels, startIndex is the line at the top of it
+	// and scrollOffset how much of that line is scrolled off
+	scroll       Scroller
+	scrollOffset int
+	updates      FixedStep
+
+	// the words on the page, which one the mouse is over and which one
+	// we clicked on
+	wordRects    []WordRects
+	mouseOver    []bool
+	wordIndex    int
+	clicked      bool
+	clearWord    bool
+	selectedWord string
+	textCache    map[string]string
+
+	// selecting with the mouse
+	selecting           bool // the button is down
+	dragged             bool
+	released            bool
+	selectStartX        int
+	selectEndX          int
+	selectY             int
+	selectNotYetSet     bool
+	startSelectionRange int
+	selectedLine        int32
+	hiRects             *HiLineRects
+
+	// offsets of the last thing we selected with the mouse
+	selStart     int
+	selEnd       int
+	hasSelection bool
+
+	highlightColor HighlightColor
+	lastHighlight  int // offset of the last highlight we made
+	noteTarget     Highlight
+
+	bookmarks      []Bookmark
+	bookmarkCursor int
+
+	prompt Prompt
+	search *Search
+	keys   Keymap
+
+	// vim mode, the keys in vimKeys go first and there's a word cursor
+	vim      bool
+	vimKeys  Keymap
+	count    int  // the digits typed so far
+	pendingG bool // g was pressed, another one goes to the top
+	cursor   int  // offset of the word the cursor is on, -1 for none
+
+	showBookmarks    bool
+	showConcordance  bool
+	showHUD          bool
+	showHelp         bool
+	concordanceLines []string
+
+	// what changed since Render last redrew the overlays
+	bookmarksChanged   bool
+	promptChanged      bool
+	concordanceChanged bool
+	helpChanged        bool
+}
+
+// NewApp opens doc at the position we left it at, on a w by h page
+func NewApp(doc *Document, fam *FontFamily, db *bolt.DB, lem Lemmatizer, w, h int, scale DisplayScale) *App {
+	app := &App{
+		doc:             doc,
+		fam:             fam,
+		db:              db,
+		lem:             lem,
+		textDir:         "./text/",
+		running:         true,
+		clock:           systemClock{},
+		scale:           scale,
+		atlas:           NewGlyphAtlas(),
+		fontSize:        defaultFontSize,
+		pageTop:         scale.Px(pageMarginTop),
+		pageLeft:        scale.Px(pageMarginLeft),
+		jumpToLine:      -1,
+		updates:         FixedStep{Step: updateStep},
+		wordIndex:       -1,
+		textCache:       make(map[string]string),
+		selectNotYetSet: true,
+		highlightColor:  HighlightYellow,
+		lastHighlight:   -1,
+		search:          NewSearch(),
+		keys:            DefaultKeymap(),
+		vimKeys:         DefaultVimKeymap(),
+		cursor:          -1,
+	}
+	app.anims = NewAnimator(app)
+	app.scroll.Clock = app
+	doc.Left = app.pageLeft
+
+	app.bg = image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(app.bg, app.bg.Bounds(), pageBGColor, image.Point{0, 0}, draw.Src)
+	app.ctx = NewPageContext(fam, app.bg, scale.DPI, app.fontSize)
+	app.damage = NewDamage(app.bg.Bounds())
+	app.numLines = PageLines(h, app.pageTop, app.lineHeight())
+
+	lastOffset, err := DBLoadPosition(db, doc.Name)
+	if err != nil {
+		fmt.Println(err)
+	}
+	app.scroll.SetLines(len(doc.Lines), app.numLines)
+	app.scroll.Jump(float64(doc.LineAt(lastOffset)))
+	app.startIndex = int(app.scroll.Pos)
+
+	if doc.Highlights, err = DBLoadHighlights(db, doc.Name); err != nil {
+		fmt.Println(err)
+	}
+	if app.bookmarks, err = DBLoadBookmarks(db, doc.Name); err != nil {
+		fmt.Println(err)
+	}
+
+	// ---- page allocs ----
+	numAllocs := 0
+	for i := 0; i < app.numLines && i < len(doc.Lines); i++ {
+		numAllocs += CountSpacesBetweenWords(doc.Lines[i])
+	}
+	numAllocs = numAllocs * 2 // alloc size subject to change
+	app.wordRects = make([]WordRects, numAllocs)
+	app.mouseOver = make([]bool, numAllocs)
+	// ---- page allocs ----
+
+	app.drawPage()
+	fmt.Printf("len of page_elem_len_x %d\n", len(app.wordRects))
+
+	// + 1 because we have a 0 based indexing
+	app.hiRects = NewHiLineRects(app.numLines+1, int32(app.pageLeft), 0)
+	return app
+}
+
+// MouseOverWords marks the words in r that x, y (page pixels) is over,
+// a word reaches up a line from the rect under it
+func MouseOverWords(x, y int, lineHeight int, r *[]WordRects, mouseOver *[]bool) {
+	// DrawToCtx grows r when a page has more words than we allocated for
+	if len(*mouseOver) < len(*r) {
+		*mouseOver = append(*mouseOver, make([]bool, len(*r)-len(*mouseOver))...)
+	}
+	for index := range *r {
+		mx_gt_rx := x > (*r)[index].Rect.Min.X
+		mx_lt_rx_rw := x < (*r)[index].Rect.Max.X
+		my_gt_ry := y > (*r)[index].Rect.Min.Y-lineHeight
+		my_lt_ry_rh := y < (*r)[index].Rect.Max.Y
+
+		if (mx_gt_rx && mx_lt_rx_rw) && (my_gt_ry && my_lt_ry_rh) {
+			(*mouseOver)[index] = true
+		} else {
+			(*mouseOver)[index] = false
+		}
+	}
+}
+
+// Now is the time for the tweens and the scroller, so that a test can
+// swap the clock of all of them at once
+func (app *App) Now() time.Time {
+	return app.clock.Now()
+}
+
+func (app *App) lineHeight() int {
+	return app.ctx.PointToFixed(app.fontSize).Round()
+}
+
+// overlays are in drawable pixels too, so is their font size
+func (app *App) overlayFontSize() float64 {
+	return 14 * app.scale.Factor()
+}
+
+func (app *App) size() (int32, int32) {
+	return int32(app.bg.Bounds().Dx()), int32(app.bg.Bounds().Dy())
+}
+
+func (app *App) bookmarkRect() sdl.Rect {
+	w, h := app.size()
+	panelW := int32(app.scale.Px(220))
+	return sdl.Rect{X: w - panelW, Y: 0, W: panelW, H: h}
+}
+
+func (app *App) promptRect() sdl.Rect {
+	w, h := app.size()
+	barH := int32(app.scale.Px(28))
+	return sdl.Rect{X: 0, Y: h - barH, W: w, H: barH}
+}
+
+func (app *App) concordanceRect() sdl.Rect {
+	return sdl.Rect{X: int32(app.scale.Px(20)), Y: int32(app.scale.Px(20)), W: int32(app.scale.Px(600)), H: int32(app.scale.Px(440))}
+}
+
+func (app *App) helpRect() sdl.Rect {
+	return sdl.Rect{X: int32(app.scale.Px(20)), Y: int32(app.scale.Px(20)), W: int32(app.scale.Px(400)), H: int32(app.scale.Px(400))}
+}
+
+func (app *App) hudRect() sdl.Rect {
+	return sdl.Rect{X: int32(app.scale.Px(10)), Y: int32(app.scale.Px(10)), W: int32(app.scale.Px(320)), H: int32(app.scale.Px(200))}
+}
+
+func (app *App) drawPage() {
+	start, raster := time.Now(), app.atlas.RasterTime
+	defer func() { app.layoutTime += time.Since(start) - (app.atlas.RasterTime - raster) }()
+	app.pageChanged = true
+
+	for _, r := range app.pageRects {
+		draw.Draw(app.bg, r, pageBGColor, image.Point{0, 0}, draw.Src)
+	}
+	app.damage.Add(app.pageRects...)
+
+	app.ctx.SetFontSize(app.fontSize)
+	pt := freetype.Pt(app.pageLeft, app.pageTop)
+
+	// one more line than fits, it shows at the bottom while scrolling
+	app.pageRects = DrawPageRects(app.bg, app.ctx, pt, app.doc, app.fam, app.startIndex, app.numLines+1, app.fontSize, &app.wordRects)
+	app.damage.Add(app.pageRects...)
+	app.atlas.SetPage(app.doc, app.fam, pt, app.startIndex, app.numLines+1, PixelSize(app.ctx, app.fontSize), app.ctx.PointToFixed(app.fontSize))
+}
+
+// Idle is true when nothing is moving and the last frame is still what
+// the screen should show
+func (app *App) Idle() bool {
+	return !app.pageChanged && app.damage.Empty() && !app.scroll.Moving() && !app.anims.Running()
+}
+
+// pageLines counts the lines that fit again, that changes with the font
+// size and so does how far the page scrolls
+func (app *App) pageLines() {
+	numLines := PageLines(app.bg.Bounds().Dy(), app.pageTop, app.lineHeight())
+	app.scroll.SetLines(len(app.doc.Lines), numLines)
+	if numLines != app.numLines {
+		app.numLines = numLines
+		// + 1 because we have a 0 based indexing
+		app.hiRects = NewHiLineRects(app.numLines+1, int32(app.pageLeft), 0)
+	}
+}
+
+func (app *App) zoom(step float64) {
+	size := app.anims.Target(&app.fontSize) + step
+	size = math.Max(minFontSize, math.Min(size, maxFontSize))
+	app.anims.To(&app.fontSize, size, zoomDuration, EaseOutCubic)
+}
+
+// scrolls so that the match is the top line, unless it's already on the page
+func (app *App) showMatch(m SearchMatch) {
+	if m.Line < app.startIndex || m.Line >= app.startIndex+app.numLines {
+		app.jumpToLine = m.Line
+	}
+}
+
+// reruns the search after the query or the options changed
+func (app *App) updateSearch() {
+	app.search.Update(app.doc, app.prompt.Text, app.startIndex)
+	if app.search.Current >= 0 {
+		app.showMatch(app.search.Matches[app.search.Current])
+	}
+	app.prompt.Label = app.search.Label()
+	app.promptChanged = true
+}
+
+// the highlight we act on with c/n/x: whatever is under the
+// selection, otherwise the last one we made
+func (app *App) targetHighlight() int {
+	if app.hasSelection {
+		return HighlightAt(app.doc.Highlights, app.selStart)
+	}
+	return HighlightAt(app.doc.Highlights, app.lastHighlight)
+}
+
+func (app *App) reloadHighlights() {
+	var err error
+	if app.doc.Highlights, err = DBLoadHighlights(app.db, app.doc.Name); err != nil {
+		fmt.Println(err)
+	}
+	app.redrawPage = true
+}
+
+func (app *App) reloadBookmarks() {
+	var err error
+	if app.bookmarks, err = DBLoadBookmarks(app.db, app.doc.Name); err != nil {
+		fmt.Println(err)
+	}
+	app.bookmarksChanged = true
+}
+
+func (app *App) openPrompt(kind PromptKind, label, text string) {
+	app.prompt.Open(kind, label)
+	app.prompt.Text = text
+	app.promptChanged = true
+}
+
+// HandleEvent is Do for every action event turns into
+func (app *App) HandleEvent(event sdl.Event) {
+	for _, a := range app.Actions(event) {
+		app.Do(a)
+	}
+}
+
+// Do is where actions change the state, what they look like on the
+// screen is left to Update and Render
+func (app *App) Do(a Action) {
+	n := 1
+	if a.N > 0 {
+		n = a.N
+	}
+
+	switch a.Kind {
+	case ActionQuit:
+		app.running = false
+
+	case ActionLineUp:
+		app.scroll.ScrollBy(-float64(n))
+	case ActionLineDown:
+		app.scroll.ScrollBy(float64(n))
+	case ActionPageUp:
+		app.scroll.ScrollBy(-float64(n * app.numLines))
+	case ActionPageDown:
+		app.scroll.ScrollBy(float64(n * app.numLines))
+	case ActionHalfPageUp:
+		app.scroll.ScrollBy(-float64(n * app.numLines / 2))
+	case ActionHalfPageDown:
+		app.scroll.ScrollBy(float64(n * app.numLines / 2))
+	case ActionTop:
+		app.goToLine(n - 1)
+	case ActionBottom:
+		if a.N > 0 {
+			app.goToLine(a.N - 1)
+		} else {
+			app.goToLine(len(app.doc.Lines) - 1)
+		}
+	case ActionScroll:
+		app.scroll.Fling(-a.Amount * wheelVelocity)
+	case ActionZoomIn:
+		app.zoom(zoomStep)
+	case ActionZoomOut:
+		app.zoom(-zoomStep)
+
+	case ActionMouseMove:
+		y := a.Y + app.scrollOffset // the words are where they are in bg
+		MouseOverWords(a.X, y, app.lineHeight(), &app.wordRects, &app.mouseOver)
+
+		// check go doc sdl.MouseMotionEvent for solution
+		if app.selecting {
+			app.selectEndX = a.X
+			app.dragged = true
+		}
+		if app.dragged {
+			app.selectY = y
+		}
+	case ActionMouseDown:
+		if !app.selecting {
+			app.selecting = true
+			app.selectStartX = a.X
+			app.released = false
+			app.hasSelection = false
+		}
+	case ActionMouseUp:
+		app.clicked = true
+		if app.selecting {
+			app.selecting = false
+			app.released = true
+		}
+
+	case ActionHighlight:
+		if app.hasSelection {
+			hl := Highlight{Start: app.selStart, End: app.selEnd, Color: app.highlightColor}
+			if err := DBSaveHighlight(app.db, app.doc.Name, hl); err != nil {
+				fmt.Println(err)
+			}
+			app.reloadHighlights()
+			app.lastHighlight = hl.Start
+		}
+	case ActionHighlightColor:
+		// without a highlight to change there's nothing to see, so the
+		// color for the next one stays what it was
+		if i := app.targetHighlight(); i >= 0 {
+			hl := app.doc.Highlights[i]
+			hl.Color = hl.Color.Next()
+			app.highlightColor = hl.Color
+			fmt.Printf("highlight color: %s\n", app.highlightColor)
+			if err := DBSaveHighlight(app.db, app.doc.Name, hl); err != nil {
+				fmt.Println(err)
+			}
+			app.reloadHighlights()
+		}
+	case ActionNote:
+		if i := app.targetHighlight(); i >= 0 {
+			app.noteTarget = app.doc.Highlights[i]
+			app.openPrompt(PromptNote, "note: ", app.noteTarget.Note)
+		}
+	case ActionDeleteHighlight:
+		if i := app.targetHighlight(); i >= 0 {
+			if err := DBDeleteHighlight(app.db, app.doc.Name, app.doc.Highlights[i]); err != nil {
+				fmt.Println(err)
+			}
+			app.reloadHighlights()
+		}
+	case ActionExport:
+		exportDst := app.doc.Name + ".highlights.md"
+		exportf, err := os.Create(exportDst)
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		if err = ExportHighlightsMarkdown(exportf, app.doc, app.doc.Highlights); err != nil {
+			fmt.Println(err)
+		}
+		exportf.Close()
+		fmt.Printf("exported %d highlights to %s\n", len(app.doc.Highlights), exportDst)
+	case ActionConcordance:
+		app.concordance()
+
+	case ActionBookmark:
+		app.openPrompt(PromptBookmark, "bookmark name: ", "")
+	case ActionToggleBookmarks:
+		app.showBookmarks = !app.showBookmarks
+		app.bookmarksChanged = true
+	case ActionBookmarkUp:
+		if app.bookmarkCursor > 0 {
+			app.bookmarkCursor -= 1
+		}
+		app.bookmarksChanged = true
+	case ActionBookmarkDown:
+		if app.bookmarkCursor < len(app.bookmarks)-1 {
+			app.bookmarkCursor += 1
+		}
+		app.bookmarksChanged = true
+	case ActionBookmarkGo:
+		if a.N >= 0 {
+			app.bookmarkCursor = a.N
+		}
+		if app.bookmarkCursor < len(app.bookmarks) {
+			app.jumpToLine = app.doc.LineAt(app.bookmarks[app.bookmarkCursor].Start)
+		}
+		app.bookmarksChanged = true
+	case ActionBookmarkDelete:
+		if app.bookmarkCursor < len(app.bookmarks) {
+			if err := DBDeleteBookmark(app.db, app.doc.Name, app.bookmarks[app.bookmarkCursor]); err != nil {
+				fmt.Println(err)
+			}
+			app.reloadBookmarks()
+			if app.bookmarkCursor > 0 && app.bookmarkCursor >= len(app.bookmarks) {
+				app.bookmarkCursor = len(app.bookmarks) - 1
+			}
+		}
+	case ActionClosePanels:
+		app.showBookmarks = false
+		app.showConcordance = false
+		app.showHelp = false
+	case ActionToggleHUD:
+		app.showHUD = !app.showHUD
+	case ActionHelp:
+		app.showHelp = !app.showHelp
+		app.helpChanged = true
+
+	case ActionVim:
+		app.vim = !app.vim
+		app.count, app.pendingG = 0, false
+		app.cursor = -1
+		if app.vim {
+			if offset, ok := app.wordFrom(app.startIndex, true); ok {
+				app.cursor = offset
+			}
+		}
+		app.helpChanged = true
+	case ActionWordNext:
+		app.moveCursor(n, true)
+	case ActionWordPrev:
+		app.moveCursor(n, false)
+	case ActionLookup:
+		if w, ok := app.cursorText(); ok {
+			app.lookup(w)
+		}
+	case ActionIgnoreWord:
+		if w, ok := app.cursorText(); ok {
+			key := WordKey(app.lem, w)
+			ignored, err := DBToggleIgnored(app.db, key)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Printf("'%s' (%s) ignored = %v\n", w, key, ignored)
+		}
+
+	case ActionSearch:
+		app.openPrompt(PromptSearch, app.search.Label(), app.search.Query)
+	case ActionSearchNext, ActionSearchPrev:
+		var (
+			m  SearchMatch
+			ok bool
+		)
+		if a.Kind == ActionSearchPrev {
+			m, ok = app.search.Prev()
+		} else {
+			m, ok = app.search.Next()
+		}
+		if ok {
+			app.showMatch(m)
+		}
+		app.prompt.Label = app.search.Label()
+		app.promptChanged = true
+	case ActionSearchIgnoreCase:
+		app.search.Options.IgnoreCase = !app.search.Options.IgnoreCase
+		app.updateSearch()
+	case ActionSearchIgnoreDiacritics:
+		app.search.Options.IgnoreDiacritics = !app.search.Options.IgnoreDiacritics
+		app.updateSearch()
+
+	case ActionPromptInsert:
+		app.prompt.Insert(a.Text)
+		if app.prompt.Kind == PromptSearch {
+			app.updateSearch()
+		}
+		app.promptChanged = true
+	case ActionPromptBackspace:
+		app.prompt.Backspace()
+		if app.prompt.Kind == PromptSearch {
+			app.updateSearch()
+		}
+		app.promptChanged = true
+	case ActionPromptCancel:
+		if app.prompt.Kind == PromptSearch {
+			app.search.Clear()
+		}
+		app.prompt.Close()
+	case ActionPromptSubmit:
+		app.submitPrompt()
+	}
+}
+
+func (app *App) submitPrompt() {
+	switch app.prompt.Kind {
+	case PromptBookmark:
+		start := app.doc.LineOffset(app.startIndex)
+		end := start
+		if app.hasSelection {
+			start, end = app.selStart, app.selEnd
+		}
+		bm := NewBookmark(app.doc, app.prompt.Text, start, end)
+		if err := DBSaveBookmark(app.db, app.doc.Name, bm); err != nil {
+			fmt.Println(err)
+		}
+		app.reloadBookmarks()
+	case PromptNote:
+		app.noteTarget.Note = strings.TrimSpace(app.prompt.Text)
+		if err := DBSaveHighlight(app.db, app.doc.Name, app.noteTarget); err != nil {
+			fmt.Println(err)
+		}
+		app.reloadHighlights()
+	}
+	app.prompt.Close()
+}
+
+func (app *App) concordance() {
+	if app.selectedWord == "" {
+		return
+	}
+	if n, err := DBBuildConcordance(app.db, app.textDir); err != nil {
+		fmt.Println(err)
+	} else if n > 0 {
+		fmt.Printf("concordance: indexed %d texts\n", n)
+		app.textCache = make(map[string]string)
+	}
+	hits, err := DBConcordance(app.db, app.selectedWord, 50)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	sentences, err := ConcordanceSentences(app.textDir, hits, app.textCache)
+	if err != nil {
+		fmt.Println(err)
+	}
+	lines := []string{fmt.Sprintf("%q in %d sentences", app.selectedWord, len(hits))}
+	app.concordanceLines = append(lines, ConcordanceLines(hits, sentences, 580, 14/2)...)
+	app.concordanceChanged = true
+	app.showConcordance = true
+}
+
+// Resize is for when the page is w by h now (the window changed size or
+// moved to another display), bg is made again and the text is wrapped to
+// the new width
+func (app *App) Resize(w, h int, scale DisplayScale) {
+	if w <= 0 || h <= 0 || (image.Pt(w, h) == app.bg.Bounds().Size() && scale == app.scale) {
+		return
+	}
+	if scale != app.scale {
+		app.scale = scale
+		app.ctx.SetDPI(scale.DPI)
+		app.pageTop = scale.Px(pageMarginTop)
+		app.pageLeft = scale.Px(pageMarginLeft)
+		app.doc.Left = app.pageLeft
+		app.doc.Width = 0 // the characters got wider, so rewrap no matter what
+	}
+	app.bg = image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(app.bg, app.bg.Bounds(), pageBGColor, image.Point{0, 0}, draw.Src)
+	app.ctx.SetClip(app.bg.Bounds())
+	app.ctx.SetDst(app.bg)
+	app.damage.Reset(app.bg.Bounds())
+	app.pageRects = nil
+
+	// keep the same text at the top of the page
+	offset := app.doc.LineOffset(app.startIndex)
+	charW := scale.Px(charWidth)
+	width := w - scale.Px(pageMarginRight)
+	if width < 10*charW {
+		width = 10 * charW
+	}
+	if app.doc.Rewrap(width, charW) {
+		app.startIndex = app.doc.LineAt(offset)
+		app.scroll.Jump(float64(app.startIndex))
+		if app.search.Query != "" {
+			app.search.Update(app.doc, app.search.Query, app.startIndex)
+		}
+		app.hasSelection = false
+	}
+
+	app.pageLines()
+	app.hiRects = NewHiLineRects(app.numLines+1, int32(app.pageLeft), 0)
+	app.bookmarksChanged, app.promptChanged = true, true
+	app.redrawPage = true
+}
+
+// Update is what happens once a frame: clicks and selections that came
+// in are worked out, the page scrolls and zooms
+func (app *App) Update(now time.Time) {
+	if app.clicked {
+		app.clickWord()
+		app.clicked = false
+	}
+	if app.dragged {
+		app.updateSelection()
+	}
+
+	if app.clearWord {
+		colorG := image.NewUniform(color.RGBA{0, 255, 0, 108})
+		draw.Draw(app.bg, app.wordRects[app.wordIndex].Rect, colorG, image.Point{0, 0}, draw.Src)
+		app.damage.Add(app.wordRects[app.wordIndex].Rect)
+		app.wordIndex = -1
+		app.clearWord = false
+	}
+
+	if app.jumpToLine >= 0 {
+		app.scroll.Jump(float64(app.jumpToLine))
+		app.startIndex, app.scrollOffset = app.scroll.Line(app.lineHeight())
+		app.jumpToLine = -1
+		app.redrawPage = true
+	}
+
+	if app.redrawPage {
+		app.redrawPage = false
+		app.drawPage()
+	}
+
+	// the page is only drawn again when another line got to the top
+	app.scroll.SetLines(len(app.doc.Lines), app.numLines)
+	scrolled := false
+	for n := app.updates.Steps(now); n > 0; n-- {
+		scrolled = app.scroll.Step() || scrolled
+	}
+	if scrolled {
+		line, offset := app.scroll.Line(app.lineHeight())
+		app.scrollOffset = offset
+		if line != app.startIndex {
+			app.startIndex = line
+			app.drawPage()
+		}
+	}
+
+	// zooming changes how many lines there are on the page and how tall
+	// the scrolled off part of the top one is
+	if app.anims.Update() {
+		app.pageLines()
+		_, app.scrollOffset = app.scroll.Line(app.lineHeight())
+		app.drawPage()
+	}
+}
+
+// clickWord marks the word under the mouse and looks it up
+func (app *App) clickWord() {
+	for i := 0; i < len(app.mouseOver); i++ {
+		if app.mouseOver[i] == true && app.wordIndex != i {
+			if app.wordIndex < 0 { // guard against -1 index
+				app.wordIndex = 0
+			}
+
+			// clear
+			colorG := image.NewUniform(color.RGBA{0, 255, 0, 108})
+			draw.Draw(app.bg, app.wordRects[app.wordIndex].Rect, colorG, image.Point{0, 0}, draw.Src)
+			app.damage.Add(app.wordRects[app.wordIndex].Rect)
+
+			// draw
+			colorB := image.NewUniform(color.RGBA{0, 0, 244, 108})
+			draw.Draw(app.bg, app.wordRects[i].Rect, colorB, image.Point{0, 0}, draw.Src)
+			app.damage.Add(app.wordRects[i].Rect)
+
+			app.wordIndex = i
+		}
+
+		if app.mouseOver[i] == true && app.wordIndex == i {
+			app.lookup(GetWord(&app.doc.Lines, &app.wordRects, app.wordIndex))
+			app.clearWord = false
+			break
+		}
+
+		if app.wordIndex >= 0 {
+			app.clearWord = true
+		}
+	}
+}
+
+// lookup is what clicking on w does, it's the word the concordance is for
+// after that
+func (app *App) lookup(w string) {
+	app.selectedWord = w
+	lemma := WordKey(app.lem, w)
+	exists, err := DBView(app.db, lemma)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	const msg = "'%s' (%s) exists in the database = %q\n"
+	fmt.Printf(msg, w, lemma, exists)
+
+	if forms, err := DBForms(app.db, lemma); err == nil && len(forms) > 1 {
+		fmt.Printf("forms of '%s': %s\n", lemma, strings.Join(forms, ", "))
+	}
+}
+
+// helpLines is what the help overlay shows, the keys of the mode we're in
+func (app *App) helpLines() []string {
+	if app.vim {
+		return append(app.vimHelp(), fmt.Sprintf("%-18s %s", "normal mode", strings.Join(app.keys.Keys(ActionBack), ", ")))
+	}
+	return app.keys.Help()
+}
+
+// updateSelection follows the mouse while it's dragged, the rects of the
+// lines it went over are in hiRects and once the button is released the
+// selection ends up in selStart and selEnd
+func (app *App) updateSelection() {
+	start := GetSelectedCharLen(app.selectStartX)
+	if start > 0 {
+		start -= 1
+	}
+	end := GetSelectedCharLen(app.selectEndX)
+
+	lineHeight := app.lineHeight()
+	app.selectedLine = int32(YCoordToNumLines(app.selectY, lineHeight))
+	hiRects := app.hiRects
+	selectedLine := app.selectedLine
+
+	markLast := false
+	wentDown := false
+
+	if hiRects.currln < selectedLine {
+		hiRects.currln = selectedLine
+		wentDown = true
+	} else if hiRects.currln > selectedLine {
+		markLast = true
+	}
+
+	lineY := lineHeight * (int(selectedLine) + 1)
+
+	// used for selecting doc.Lines after paging or scrolling
+	startIndex32 := int32(app.startIndex)
+	size := PixelSize(app.ctx, app.fontSize)
+
+	if selectedLine <= int32(app.numLines) {
+		println(selectedLine)
+		hiRects.rects[selectedLine].Y = int32(lineY - app.pageLeft)
+
+		// the layouts are cached by the document and know about bold and italic
+		// the right end of the line on the page
+		maxWidth := app.pageLeft + app.doc.Layout(int(selectedLine+startIndex32), app.fam, size).Width.Round()
+
+		// we need to track selectStartX
+		// (!) we can probably solve our selection problems by using selectStartX
+		if app.selectStartX >= app.pageLeft && app.selectStartX <= maxWidth && app.selectNotYetSet {
+			hiRects.rects[selectedLine].X = int32(app.selectStartX)
+			app.selectNotYetSet = false
+			app.startSelectionRange = int(selectedLine)
+		}
+
+		if app.selectEndX < maxWidth && !wentDown {
+			hiRects.rects[selectedLine].W = int32(app.selectEndX) - hiRects.rects[selectedLine].X
+		} else if app.selectEndX < maxWidth && wentDown || app.selectEndX >= maxWidth && wentDown {
+			prevSelectedLine := selectedLine
+			if prevSelectedLine > 0 {
+				prevSelectedLine -= 1
+			}
+			maxWidth = app.pageLeft + app.doc.Layout(int(prevSelectedLine+startIndex32), app.fam, size).Width.Round()
+			hiRects.rects[prevSelectedLine].W = int32(maxWidth) - hiRects.rects[prevSelectedLine].X
+
+			// this is why we couldn't properly render maxWidth when we start at a latter X
+			// it's because we need to select the prevLine instead of selectedLine!
+		} else if app.selectEndX > maxWidth {
+			hiRects.rects[selectedLine].W = int32(maxWidth) - hiRects.rects[selectedLine].X
+		}
+
+		if markLast {
+			hiRects.UnShowRangeFrom(int(selectedLine))
+			hiRects.currln = selectedLine
+		}
+
+		// loop through and select range from start up to selectedLine
+		// so that we catch any lines that were missed
+		hiRects.Show(app.startSelectionRange, int(selectedLine))
+
+		for i := int32(0); i < selectedLine; i++ {
+			// we don't set rects[i].W on selection start
+			if hiRects.IsShown(int(i)) && i != int32(app.startSelectionRange) {
+				// the following two lines are needed to set the Y properly
+				lineY = lineHeight * (int(i) + 1)
+				hiRects.rects[i].Y = int32(lineY - app.pageLeft)
+				hiRects.rects[i].W = int32(app.doc.Layout(int(i+startIndex32), app.fam, size).Width.Round())
+			}
+		}
+	}
+
+	if app.released {
+		app.selStart, app.selEnd = app.doc.SelectionRange(app.startSelectionRange+app.startIndex, start,
+			int(selectedLine)+app.startIndex, end)
+		app.hasSelection = app.selStart != app.selEnd
+		if app.hasSelection {
+			fmt.Println(app.doc.Text[app.selStart:app.selEnd])
+		}
+
+		// reset
+		app.selectNotYetSet = true
+		app.startSelectionRange = 0
+		app.dragged = false
+		app.released = false
+		hiRects.UnShowAllAndReset(int32(app.pageLeft))
+	}
+}
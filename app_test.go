@@ -0,0 +1,232 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veandco/go-sdl2/sdl"
+	bolt "go.etcd.io/bbolt"
+)
+
+// an App on a 640x480 page at 72 DPI, with a fake clock and a db of its own
+func testApp(t *testing.T) (*App, *FakeClock) {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), FILE_MODE_RW, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	doc := NewDocument("test.txt", strings.Repeat(goldenText+"\n", 20), 640-pageMarginRight, charWidth, nil)
+	lem := IdentityLemmatizer{}
+	if err := DBInit(db, GetUniqueWords(doc.Lines, lem, doc.Segmenter)); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := NewFakeClock()
+	app := NewApp(doc, testFamily(t), db, lem, 640, 480, DisplayScale{Drawable: 1, DPI: 72})
+	app.clock = clock
+	app.textDir = t.TempDir()
+	return app, clock
+}
+
+// runs frames until nothing moves anymore, like the loop does before it
+// goes to sleep
+func settleApp(t *testing.T, app *App, clock *FakeClock) {
+	t.Helper()
+	for n := 0; n < 300; n++ {
+		// a page that was drawn again gets one more frame, see App.Idle
+		app.pageChanged = false
+		app.Update(clock.Now())
+		if !app.scroll.Moving() && !app.anims.Running() && !app.pageChanged {
+			return
+		}
+		clock.Advance(testFrame)
+	}
+	t.Fatalf("still moving after 300 frames, pos %f", app.scroll.Pos)
+}
+
+func keyUp(sym sdl.Keycode, mod uint16) *sdl.KeyboardEvent {
+	return &sdl.KeyboardEvent{Type: sdl.KEYUP, Keysym: sdl.Keysym{Sym: sym, Mod: mod}}
+}
+
+func TestAppPaging(t *testing.T) {
+	const msg = "ntest: %d, got: %d, want %d\n"
+	tests := []struct {
+		keys []sdl.Keycode
+		want func(app *App) int
+	}{
+		{[]sdl.Keycode{sdl.K_RIGHT}, func(app *App) int { return app.numLines }},
+		{[]sdl.Keycode{sdl.K_RIGHT, sdl.K_LEFT}, func(app *App) int { return 0 }},
+		{[]sdl.Keycode{sdl.K_LEFT}, func(app *App) int { return 0 }},
+		{[]sdl.Keycode{sdl.K_DOWN, sdl.K_DOWN, sdl.K_UP}, func(app *App) int { return 1 }},
+		{[]sdl.Keycode{sdl.K_RIGHT, sdl.K_RIGHT, sdl.K_RIGHT, sdl.K_RIGHT}, func(app *App) int { return int(app.scroll.Max) }},
+	}
+
+	for i, test := range tests {
+		app, clock := testApp(t)
+		for _, key := range test.keys {
+			app.HandleEvent(keyUp(key, sdl.KMOD_NONE))
+		}
+		settleApp(t, app, clock)
+
+		if want := test.want(app); app.startIndex != want || app.scrollOffset != 0 {
+			t.Errorf(msg, i, app.startIndex, want)
+		}
+	}
+}
+
+func TestAppZoomScroll(t *testing.T) {
+	const msg = "ntest: %d, got: %d, want %d\n"
+	tests := []struct {
+		keys string
+	}{
+		{"f f f right right right right right right"},
+		{"right right right right right right b b b b b b"},
+		{"b b b b b b right right right right right right"},
+		{"right right right right right right f f f f f right"},
+	}
+
+	for i, test := range tests {
+		app, clock := testApp(t)
+		for _, k := range strings.Fields(test.keys) {
+			pressKeys(t, app, k)
+			settleApp(t, app, clock)
+		}
+
+		// the page still goes down to the last line, and no further
+		if want := PageLines(480, app.pageTop, app.lineHeight()); app.numLines != want {
+			t.Errorf(msg, i, app.numLines, want)
+		}
+		if want := len(app.doc.Lines) - app.numLines; app.startIndex != want || app.scrollOffset != 0 {
+			t.Errorf(msg, i, app.startIndex, want)
+		}
+	}
+}
+
+func TestAppClickWord(t *testing.T) {
+	app, clock := testApp(t)
+	r := app.wordRects[0].Rect
+
+	app.HandleEvent(&sdl.MouseMotionEvent{Type: sdl.MOUSEMOTION, X: int32(r.Min.X + 1), Y: int32(r.Min.Y + 1)})
+	app.HandleEvent(&sdl.MouseButtonEvent{Type: sdl.MOUSEBUTTONDOWN, State: sdl.PRESSED, X: int32(r.Min.X + 1), Y: int32(r.Min.Y + 1)})
+	app.HandleEvent(&sdl.MouseButtonEvent{Type: sdl.MOUSEBUTTONUP, State: sdl.RELEASED, X: int32(r.Min.X + 1), Y: int32(r.Min.Y + 1)})
+	settleApp(t, app, clock)
+
+	if app.selectedWord != "It" || app.wordIndex != 0 {
+		t.Errorf("got: %q (%d), want %q (0)\n", app.selectedWord, app.wordIndex, "It")
+	}
+	if app.hasSelection {
+		t.Errorf("a click selected %d:%d\n", app.selStart, app.selEnd)
+	}
+}
+
+func TestAppSearchPrompt(t *testing.T) {
+	app, clock := testApp(t)
+
+	app.HandleEvent(keyUp(sdl.K_f, sdl.KMOD_LCTRL))
+	if !app.prompt.IsOpen() || app.prompt.Kind != PromptSearch {
+		t.Fatalf("ctrl+f didn't open the search bar\n")
+	}
+
+	input := &sdl.TextInputEvent{Type: sdl.TEXTINPUT}
+	copy(input.Text[:], "clocks")
+	app.HandleEvent(input)
+	// the prompt gets the keys, this doesn't page down
+	app.HandleEvent(keyUp(sdl.K_RIGHT, sdl.KMOD_NONE))
+	settleApp(t, app, clock)
+
+	if app.prompt.Text != "clocks" || len(app.search.Matches) != 20 {
+		t.Errorf("got: %q with %d matches, want %q with 20\n", app.prompt.Text, len(app.search.Matches), "clocks")
+	}
+	if app.startIndex != 0 {
+		t.Errorf("got: %d, want 0\n", app.startIndex)
+	}
+
+	app.HandleEvent(keyUp(sdl.K_ESCAPE, sdl.KMOD_NONE))
+	if app.prompt.IsOpen() || len(app.search.Matches) != 0 || !app.running {
+		t.Errorf("esc left the search open (%v) with %d matches\n", app.prompt.IsOpen(), len(app.search.Matches))
+	}
+}
+
+func TestAppKeyActions(t *testing.T) {
+	const msg = "ntest: %d, got: %d, want %d\n"
+	tests := []struct {
+		setup func(app *App)
+		event sdl.Event
+		want  ActionKind
+	}{
+		{nil, &sdl.QuitEvent{Type: sdl.QUIT}, ActionQuit},
+		{nil, keyUp(sdl.K_ESCAPE, sdl.KMOD_NONE), ActionQuit},
+		{func(app *App) { app.showBookmarks = true }, keyUp(sdl.K_ESCAPE, sdl.KMOD_NONE), ActionClosePanels},
+		{func(app *App) { app.showBookmarks = true }, keyUp(sdl.K_DOWN, sdl.KMOD_NONE), ActionBookmarkDown},
+		{nil, keyUp(sdl.K_DOWN, sdl.KMOD_NONE), ActionLineDown},
+		{nil, keyUp(sdl.K_f, sdl.KMOD_NONE), ActionZoomIn},
+		{nil, keyUp(sdl.K_f, sdl.KMOD_LCTRL), ActionSearch},
+		{func(app *App) { app.openPrompt(PromptNote, "note: ", "") }, keyUp(sdl.K_RETURN, sdl.KMOD_NONE), ActionPromptSubmit},
+		{func(app *App) { app.openPrompt(PromptSearch, "", "") }, keyUp(sdl.K_RETURN, sdl.KMOD_LSHIFT), ActionSearchPrev},
+		{func(app *App) { app.openPrompt(PromptSearch, "", "") }, keyUp(sdl.K_h, sdl.KMOD_NONE), ActionNone},
+		{nil, &sdl.KeyboardEvent{Type: sdl.KEYDOWN, Keysym: sdl.Keysym{Sym: sdl.K_RIGHT}}, ActionNone},
+	}
+
+	app, _ := testApp(t)
+	for i, test := range tests {
+		app.prompt.Close()
+		app.showBookmarks = false
+		if test.setup != nil {
+			test.setup(app)
+		}
+
+		got := ActionNone
+		if actions := app.Actions(test.event); len(actions) > 0 {
+			got = actions[0].Kind
+		}
+		if got != test.want {
+			t.Errorf(msg, i, got, test.want)
+		}
+	}
+}
+
+// c changes the color of the highlight we're on, without one it does nothing
+func TestAppHighlightColor(t *testing.T) {
+	const msg = "ntest: %d, got: %v, want %v\n"
+	app, _ := testApp(t)
+
+	app.HandleEvent(keyUp(sdl.K_c, sdl.KMOD_NONE))
+	if app.highlightColor != HighlightYellow {
+		t.Errorf(msg, 0, app.highlightColor, HighlightYellow)
+	}
+
+	app.selStart, app.selEnd, app.hasSelection = 3, 9, true
+	app.Do(Action{Kind: ActionHighlight})
+	app.hasSelection = false
+	for i, want := range []HighlightColor{HighlightGreen, HighlightBlue} {
+		app.HandleEvent(keyUp(sdl.K_c, sdl.KMOD_NONE))
+		if len(app.doc.Highlights) != 1 || app.doc.Highlights[0].Color != want {
+			t.Errorf(msg, i+1, app.doc.Highlights, want)
+		}
+		if app.highlightColor != want {
+			t.Errorf(msg, i+1, app.highlightColor, want)
+		}
+	}
+}
+
+// the left margin is scaled like the rest of the page, the words and
+// the selection start at it
+func TestAppPageLeft(t *testing.T) {
+	const msg = "ntest: %d, got: %d, want %d\n"
+	app, _ := testApp(t)
+	for i, factor := range []float64{1, 2, 1} {
+		app.Resize(640*int(factor), 480*int(factor), DisplayScale{Drawable: factor, DPI: 72 * factor})
+		app.drawPage()
+
+		want := int(pageMarginLeft * factor)
+		if got := int(app.wordRects[0].Rect.Min.X); got != want {
+			t.Errorf(msg, i, got, want)
+		}
+		if got := int(app.hiRects.rects[0].X); got != want {
+			t.Errorf(msg, i, got, want)
+		}
+	}
+}
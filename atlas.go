@@ -0,0 +1,286 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+	"unsafe"
+
+	gotext "github.com/go-text/typesetting/font"
+	"github.com/veandco/go-sdl2/sdl"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	atlasSize      = 1024
+	atlasSubpixels = 4 // we rasterize every glyph at 4 horizontal offsets per pixel
+)
+
+type glyphKey struct {
+	font *Font
+	size fixed.Int26_6
+	id   gotext.GID
+	sub  int
+}
+
+// atlasGlyph is where a glyph ended up in the atlas, Offset is from the
+// pixel the pen is in to the top left corner of Rect. Spaces have an
+// empty Rect.
+type atlasGlyph struct {
+	Rect   image.Rectangle
+	Offset image.Point
+}
+
+// GlyphAtlas rasterizes every glyph once (per font, size and subpixel
+// offset) into one big texture, after that a page of text is a single
+// RenderGeometry call. Before this every scroll and every step of the zoom
+// animation rasterized the whole page and uploaded all 640x480 pixels.
+//
+// Glyphs are packed in shelves (rows as tall as their tallest glyph), when
+// the atlas is full it's thrown away and we start over.
+type GlyphAtlas struct {
+	img    *image.RGBA
+	glyphs map[glyphKey]atlasGlyph
+	dirty  image.Rectangle // what hasn't been uploaded to tex yet
+	resets int             // how many times the atlas filled up
+
+	// for the HUD, RasterTime is the time spent in rasterize
+	Hits, Misses int
+	RasterTime   time.Duration
+
+	shelfX, shelfY, shelfH int
+
+	tex      *sdl.Texture
+	vertices []sdl.Vertex
+	shifted  []sdl.Vertex // vertices moved up by Draw's dy
+	indices  []int32
+	color    sdl.Color
+}
+
+// NewGlyphAtlas makes an atlas without a texture, see CreateTexture
+func NewGlyphAtlas() *GlyphAtlas {
+	return &GlyphAtlas{
+		img:    image.NewRGBA(image.Rect(0, 0, atlasSize, atlasSize)),
+		glyphs: make(map[glyphKey]atlasGlyph),
+		color:  sdl.Color{R: 0, G: 0, B: 0, A: 255},
+	}
+}
+
+func (a *GlyphAtlas) CreateTexture(renderer *sdl.Renderer) error {
+	tex, err := renderer.CreateTexture(uint32(sdl.PIXELFORMAT_RGBA32), sdl.TEXTUREACCESS_STATIC, atlasSize, atlasSize)
+	if err != nil {
+		return err
+	}
+	tex.SetBlendMode(sdl.BLENDMODE_BLEND)
+	a.tex = tex
+	a.dirty = a.img.Bounds()
+	return nil
+}
+
+func (a *GlyphAtlas) Destroy() {
+	if a.tex != nil {
+		a.tex.Destroy()
+	}
+}
+
+func (a *GlyphAtlas) reset() {
+	for i := range a.img.Pix {
+		a.img.Pix[i] = 0
+	}
+	a.glyphs = make(map[glyphKey]atlasGlyph)
+	a.shelfX, a.shelfY, a.shelfH = 0, 0, 0
+	a.dirty = a.img.Bounds()
+	a.resets++
+}
+
+// alloc finds room for a w by h glyph, ok is false if it's bigger than
+// the whole atlas
+func (a *GlyphAtlas) alloc(w, h int) (image.Rectangle, bool) {
+	if w > atlasSize || h > atlasSize {
+		return image.Rectangle{}, false
+	}
+	if a.shelfX+w > atlasSize {
+		a.shelfX, a.shelfY, a.shelfH = 0, a.shelfY+a.shelfH, 0
+	}
+	if a.shelfY+h > atlasSize {
+		a.reset()
+	}
+	r := image.Rect(a.shelfX, a.shelfY, a.shelfX+w, a.shelfY+h)
+	a.shelfX += w
+	if h > a.shelfH {
+		a.shelfH = h
+	}
+	return r, true
+}
+
+// Glyph returns glyph id of font at size with the pen at dot, rasterizing
+// it if it's not in the atlas yet. dst is where it goes on the screen.
+func (a *GlyphAtlas) Glyph(font *Font, id gotext.GID, size float64, dot fixed.Point26_6) (g atlasGlyph, dst image.Point, ok bool) {
+	key := glyphKey{
+		font: font,
+		size: fixed.Int26_6(size * 64),
+		id:   id,
+		sub:  int(dot.X&63) * atlasSubpixels / 64,
+	}
+	pen := image.Pt(dot.X.Floor(), dot.Y.Floor())
+
+	g, ok = a.glyphs[key]
+	if ok {
+		a.Hits++
+	} else {
+		a.Misses++
+		g, ok = a.rasterize(key, size)
+		if !ok {
+			return g, dst, false
+		}
+		a.glyphs[key] = g
+	}
+	return g, pen.Add(g.Offset), true
+}
+
+func (a *GlyphAtlas) rasterize(key glyphKey, size float64) (atlasGlyph, bool) {
+	defer func(start time.Time) { a.RasterTime += time.Since(start) }(time.Now())
+
+	origin := fixed.Point26_6{X: fixed.Int26_6(key.sub * 64 / atlasSubpixels)}
+	bounds, ok := key.font.glyphBounds(key.id, size, origin)
+	if !ok || bounds.Empty() {
+		return atlasGlyph{}, true // nothing to draw, but don't ask again
+	}
+
+	// one pixel of padding so that linear filtering doesn't bleed
+	cell, ok := a.alloc(bounds.Dx()+1, bounds.Dy()+1)
+	if !ok {
+		return atlasGlyph{}, false
+	}
+	cell.Max = cell.Max.Sub(image.Pt(1, 1))
+
+	shift := cell.Min.Sub(bounds.Min)
+	dot := origin.Add(fixed.P(shift.X, shift.Y))
+	key.font.drawGlyph(a.img.SubImage(cell).(*image.RGBA), image.White, key.id, size, dot)
+
+	// drawGlyph leaves premultiplied white, the texture wants white with
+	// coverage in alpha so that the vertex color can tint it
+	for y := cell.Min.Y; y < cell.Max.Y; y++ {
+		row := a.img.Pix[a.img.PixOffset(cell.Min.X, y):a.img.PixOffset(cell.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			if row[i+3] > 0 {
+				row[i], row[i+1], row[i+2] = 255, 255, 255
+			}
+		}
+	}
+	a.dirty = a.dirty.Union(cell)
+	return atlasGlyph{Rect: cell, Offset: bounds.Min}, true
+}
+
+// AddGlyphs queues the quads of glyphs with the start of the baseline at pt
+func (a *GlyphAtlas) AddGlyphs(glyphs []ShapedGlyph, size float64, pt fixed.Point26_6) {
+	for _, sg := range glyphs {
+		dot := fixed.Point26_6{X: pt.X + sg.X + sg.XOffset, Y: pt.Y + sg.YOffset}
+		g, dst, ok := a.Glyph(sg.Font, sg.ID, size, dot)
+		if !ok || g.Rect.Empty() {
+			continue
+		}
+		a.addQuad(g.Rect, image.Rectangle{Min: dst, Max: dst.Add(g.Rect.Size())})
+	}
+}
+
+func (a *GlyphAtlas) addQuad(src, dst image.Rectangle) {
+	const s = float32(atlasSize)
+	base := int32(len(a.vertices))
+	corners := [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	for _, c := range corners {
+		a.vertices = append(a.vertices, sdl.Vertex{
+			Position: sdl.FPoint{
+				X: float32(dst.Min.X + c[0]*dst.Dx()),
+				Y: float32(dst.Min.Y + c[1]*dst.Dy()),
+			},
+			Color: a.color,
+			TexCoord: sdl.FPoint{
+				X: float32(src.Min.X+c[0]*src.Dx()) / s,
+				Y: float32(src.Min.Y+c[1]*src.Dy()) / s,
+			},
+		})
+	}
+	a.indices = append(a.indices, base, base+1, base+2, base, base+2, base+3)
+}
+
+// SetPage replaces whatever was queued with the lines of the page, pt is
+// the baseline of the first line (the same pt DrawToCtx gets)
+func (a *GlyphAtlas) SetPage(doc *Document, fam *FontFamily, pt fixed.Point26_6,
+	startIndex, numLines int, fontSize float64, lineHeight fixed.Int26_6) {
+	first, resets, retried := pt, a.resets, false
+	a.vertices = a.vertices[:0]
+	a.indices = a.indices[:0]
+	for n := startIndex; n < numLines+startIndex && n < len(doc.Lines); n++ {
+		linePt := pt
+		linePt.X = fixed.I(doc.LineX(n, fam, fontSize))
+		a.AddGlyphs(doc.Layout(n, fam, fontSize).Glyphs, fontSize, linePt)
+		pt.Y += lineHeight
+
+		// the atlas filled up halfway through the page, the quads we
+		// already have point at glyphs that are gone. We only start over
+		// once, a page that doesn't fit at all would go on forever.
+		if a.resets != resets && !retried {
+			retried = true
+			a.vertices = a.vertices[:0]
+			a.indices = a.indices[:0]
+			pt, n = first, startIndex-1
+		}
+	}
+}
+
+// Upload sends the glyphs that were rasterized since the last call to
+// the texture
+func (a *GlyphAtlas) Upload() error {
+	if a.tex != nil && !a.dirty.Empty() {
+		rect := sdl.Rect{
+			X: int32(a.dirty.Min.X), Y: int32(a.dirty.Min.Y),
+			W: int32(a.dirty.Dx()), H: int32(a.dirty.Dy()),
+		}
+		pixels := unsafe.Pointer(&a.img.Pix[a.img.PixOffset(a.dirty.Min.X, a.dirty.Min.Y)])
+		if err := a.tex.Update(&rect, pixels, a.img.Stride); err != nil {
+			return err
+		}
+		a.dirty = image.Rectangle{}
+	}
+	return nil
+}
+
+// Draw uploads what's new (see Upload) and draws everything that's
+// queued, dy pixels higher than it was queued
+func (a *GlyphAtlas) Draw(renderer *sdl.Renderer, dy int) error {
+	if a.tex == nil {
+		return nil
+	}
+	if err := a.Upload(); err != nil {
+		return err
+	}
+	if len(a.indices) == 0 {
+		return nil
+	}
+	if dy == 0 {
+		return renderer.RenderGeometry(a.tex, a.vertices, a.indices)
+	}
+	// scrolling by less than a line moves the quads, not the glyphs
+	a.shifted = append(a.shifted[:0], a.vertices...)
+	for i := range a.shifted {
+		a.shifted[i].Position.Y -= float32(dy)
+	}
+	return renderer.RenderGeometry(a.tex, a.shifted, a.indices)
+}
+
+// DrawTo is Draw without a renderer, the queued quads are blended into dst
+// the way RenderGeometry blends them: the vertex color with the coverage
+// in the atlas as alpha. The quads are never scaled, so every one is a
+// plain copy of its piece of the atlas. RenderPage draws with it.
+func (a *GlyphAtlas) DrawTo(dst *image.RGBA, dy int) {
+	const s = float32(atlasSize)
+	for i := 0; i+3 < len(a.vertices); i += 4 {
+		v0, v2 := a.vertices[i], a.vertices[i+2]
+		r := image.Rect(int(v0.Position.X), int(v0.Position.Y)-dy, int(v2.Position.X), int(v2.Position.Y)-dy)
+		src := image.Pt(int(v0.TexCoord.X*s+0.5), int(v0.TexCoord.Y*s+0.5))
+		c := color.RGBA{R: v0.Color.R, G: v0.Color.G, B: v0.Color.B, A: v0.Color.A}
+		draw.DrawMask(dst, r, image.NewUniform(c), image.Point{}, a.img, src, draw.Over)
+	}
+}
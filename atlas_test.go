@@ -0,0 +1,128 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+func TestGlyphAtlasCache(t *testing.T) {
+	font := testFont(t)
+	atlas := NewGlyphAtlas()
+	glyphs := font.Shape("a", 18, false)
+
+	g1, dst1, ok := atlas.Glyph(font, glyphs[0].ID, 18, fixed.P(10, 20))
+	if !ok || g1.Rect.Empty() {
+		t.Fatalf("got %v", g1)
+	}
+	// same glyph a few pixels over is the same piece of the atlas
+	g2, dst2, _ := atlas.Glyph(font, glyphs[0].ID, 18, fixed.P(30, 40))
+	if g2 != g1 || dst2.Sub(dst1) != image.Pt(20, 20) {
+		t.Errorf("got %v at %v and %v at %v", g1, dst1, g2, dst2)
+	}
+	// half a pixel over isn't
+	g3, _, _ := atlas.Glyph(font, glyphs[0].ID, 18, fixed.Point26_6{X: fixed.I(10) + 32, Y: fixed.I(20)})
+	if g3.Rect == g1.Rect {
+		t.Errorf("subpixel offset got the same rect %v", g3.Rect)
+	}
+	if len(atlas.glyphs) != 2 {
+		t.Errorf("got %d glyphs in the atlas, want 2", len(atlas.glyphs))
+	}
+}
+
+// the quads have to put the same pixels on the screen that DrawText does
+func TestGlyphAtlasMatchesDrawText(t *testing.T) {
+	font := testFont(t)
+	atlas := NewGlyphAtlas()
+
+	pt := fixed.P(5, 20)
+	want := image.NewRGBA(image.Rect(0, 0, 60, 30))
+	DrawText(want, image.White, font, 18, "H", pt)
+
+	glyphs := layoutText(font, 18, "H").Glyphs
+	atlas.AddGlyphs(glyphs, 18, pt)
+	if len(atlas.indices) != 6 {
+		t.Fatalf("got %d indices, want one quad", len(atlas.indices))
+	}
+	g, dst, _ := atlas.Glyph(font, glyphs[0].ID, 18, pt)
+	got := image.NewRGBA(want.Bounds())
+	draw.Draw(got, g.Rect.Sub(g.Rect.Min).Add(dst), atlas.img, g.Rect.Min, draw.Src)
+
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 60; x++ {
+			if a, b := got.RGBAAt(x, y).A, want.RGBAAt(x, y).A; a != b {
+				t.Fatalf("at %d,%d got alpha %d, want %d", x, y, a, b)
+			}
+		}
+	}
+	if c := atlas.img.RGBAAt(g.Rect.Min.X+g.Rect.Dx()/2, g.Rect.Min.Y+1); c.A > 0 && c.R != 255 {
+		t.Errorf("atlas pixel %v isn't white", c)
+	}
+}
+
+func TestGlyphAtlasFull(t *testing.T) {
+	font := testFont(t)
+	atlas := NewGlyphAtlas()
+	doc := NewDocument("test", "The quick brown fox jumps over the lazy dog", 400, 10, nil)
+	fam := NewFontFamily(font)
+
+	// every size is a new set of glyphs, sooner or later it fills up
+	for size := 10.0; atlas.resets == 0 && size < 400; size += 4 {
+		atlas.SetPage(doc, fam, fixed.P(10, 20), 0, 1, size, fixed.I(int(size)))
+	}
+	if atlas.resets == 0 {
+		t.Fatalf("atlas never filled up")
+	}
+
+	// after a reset the page is still all there
+	atlas.SetPage(doc, fam, fixed.P(10, 20), 0, 1, 18, fixed.I(18))
+	inked := 0
+	for _, g := range doc.Layout(0, fam, 18).Glyphs {
+		if _, ok := g.Font.glyphBounds(g.ID, 18, fixed.Point26_6{}); ok {
+			inked++
+		}
+	}
+	if got := len(atlas.indices) / 6; got != inked {
+		t.Errorf("got %d quads, want %d", got, inked)
+	}
+}
+
+// DrawTo blends the quads like RenderGeometry does, black text on white
+// comes out the same as DrawText draws it
+func TestGlyphAtlasDrawTo(t *testing.T) {
+	font := testFont(t)
+	atlas := NewGlyphAtlas()
+
+	pt := fixed.P(5, 20)
+	want := image.NewRGBA(image.Rect(0, 0, 60, 30))
+	draw.Draw(want, want.Bounds(), image.White, image.Point{}, draw.Src)
+	got := image.NewRGBA(want.Bounds())
+	draw.Draw(got, got.Bounds(), image.White, image.Point{}, draw.Src)
+	DrawText(want, image.Black, font, 18, "Hg", pt)
+
+	atlas.AddGlyphs(layoutText(font, 18, "Hg").Glyphs, 18, pt)
+	atlas.DrawTo(got, 0)
+
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 60; x++ {
+			a, b := got.RGBAAt(x, y), want.RGBAAt(x, y)
+			if d := int(a.R) - int(b.R); d > 1 || d < -1 {
+				t.Fatalf("at %d,%d got %v, want %v", x, y, a, b)
+			}
+		}
+	}
+
+	// dy moves it up like scrolling does
+	moved := image.NewRGBA(want.Bounds())
+	draw.Draw(moved, moved.Bounds(), image.White, image.Point{}, draw.Src)
+	atlas.DrawTo(moved, 3)
+	for y := 0; y < 27; y++ {
+		for x := 0; x < 60; x++ {
+			if a, b := moved.RGBAAt(x, y), got.RGBAAt(x, y+3); a != b {
+				t.Fatalf("at %d,%d got %v, want %v", x, y, a, b)
+			}
+		}
+	}
+}
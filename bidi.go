@@ -0,0 +1,235 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/bidi"
+)
+
+// BidiLine is a line of a Document in the order it goes on the screen.
+// Everything that's stored (bookmarks, highlights, word rects) uses
+// logical offsets, Ranges is how we get from those to Visual.
+type BidiLine struct {
+	RTL     bool // direction of the paragraph the line is in
+	Visual  string
+	Runs    []BidiRun // in visual order, this is what gets shaped
+	logical []int     // logical[i] is the byte in the line Visual[i] came from
+}
+
+// BidiRun is a piece of a line that goes in one direction,
+// Start and End are bytes in the logical line
+type BidiRun struct {
+	Start int
+	End   int
+	RTL   bool
+}
+
+func bidiClass(r rune) bidi.Class {
+	p, _ := bidi.LookupRune(r)
+	return p.Class()
+}
+
+// P2 and P3, the first strong character decides
+func isRTLParagraph(para string) bool {
+	for _, r := range para {
+		switch bidiClass(r) {
+		case bidi.L:
+			return false
+		case bidi.R, bidi.AL:
+			return true
+		}
+	}
+	return false
+}
+
+func hasClass(s string, classes ...bidi.Class) bool {
+	for _, r := range s {
+		c := bidiClass(r)
+		for _, want := range classes {
+			if c == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bidiLevels resolves the embedding level of every byte of a paragraph.
+// x/text/unicode/bidi does the actual work but only hands us runs with a
+// direction, so the levels are rebuilt from those: LTR runs in an RTL
+// paragraph, and numbers between RTL words, sit one level above their
+// neighbours.
+func bidiLevels(para string) ([]uint8, bool) {
+	levels := make([]uint8, len(para))
+	rtl := isRTLParagraph(para)
+	if !rtl && !hasClass(para, bidi.R, bidi.AL, bidi.AN) {
+		return levels, false // plain LTR, nothing to do
+	}
+
+	var base uint8
+	var opts []bidi.Option
+	if rtl {
+		base = 1
+		opts = append(opts, bidi.DefaultDirection(bidi.RightToLeft))
+	}
+
+	var p bidi.Paragraph
+	if _, err := p.SetString(para, opts...); err != nil {
+		return levels, rtl
+	}
+	o, err := p.Order()
+	if err != nil {
+		return levels, rtl
+	}
+
+	pos := 0
+	for i := 0; i < o.NumRuns(); i++ {
+		run := o.Run(i)
+		text := run.String()
+
+		level := uint8(1)
+		if run.Direction() == bidi.LeftToRight {
+			level = 0
+			if rtl || (!hasClass(text, bidi.L) && hasClass(text, bidi.EN, bidi.AN)) {
+				level = 2
+			}
+		}
+		for j := 0; j < len(text) && pos < len(levels); j++ {
+			levels[pos] = level
+			pos++
+		}
+	}
+	// Order stops at paragraph separators ('\r' is one)
+	for ; pos < len(levels); pos++ {
+		levels[pos] = base
+	}
+	return levels, rtl
+}
+
+// a character plus the combining marks that follow it, those have to
+// stay together when a run is reversed
+type bidiCluster struct {
+	start, end int
+	level      uint8
+}
+
+// NewBidiLine reorders line (rules L1, L2 and L4), levels are the
+// resolved levels of its bytes
+func NewBidiLine(line string, levels []uint8, rtl bool) *BidiLine {
+	var base uint8
+	if rtl {
+		base = 1
+	}
+
+	var clusters []bidiCluster
+	for i := 0; i < len(line); {
+		_, size := utf8.DecodeRuneInString(line[i:])
+		end := i + size
+		for end < len(line) {
+			r, n := utf8.DecodeRuneInString(line[end:])
+			if !unicode.In(r, unicode.Mn, unicode.Me) {
+				break
+			}
+			end += n
+		}
+		clusters = append(clusters, bidiCluster{start: i, end: end, level: levels[i]})
+		i = end
+	}
+
+	// L1, trailing whitespace goes back to the paragraph level
+	for i := len(clusters) - 1; i >= 0; i-- {
+		r, _ := utf8.DecodeRuneInString(line[clusters[i].start:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		clusters[i].level = base
+	}
+
+	// L2, from the highest level down to the lowest odd one reverse
+	// every sequence that's at that level or higher
+	var highest, lowestOdd uint8 = 0, 255
+	for _, c := range clusters {
+		if c.level > highest {
+			highest = c.level
+		}
+		if c.level%2 == 1 && c.level < lowestOdd {
+			lowestOdd = c.level
+		}
+	}
+	for level := highest; level >= lowestOdd && level > 0; level-- {
+		for i := 0; i < len(clusters); {
+			if clusters[i].level < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(clusters) && clusters[j].level >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				clusters[a], clusters[b] = clusters[b], clusters[a]
+			}
+			i = j
+		}
+	}
+
+	bl := &BidiLine{RTL: rtl, logical: make([]int, 0, len(line))}
+	for i, c := range clusters {
+		odd := c.level%2 == 1
+		if i > 0 {
+			last := &bl.Runs[len(bl.Runs)-1]
+			switch {
+			case odd == last.RTL && odd && c.end == last.Start:
+				last.Start = c.start
+				continue
+			case odd == last.RTL && !odd && c.start == last.End:
+				last.End = c.end
+				continue
+			}
+		}
+		bl.Runs = append(bl.Runs, BidiRun{Start: c.start, End: c.end, RTL: odd})
+	}
+
+	visual := make([]byte, 0, len(line))
+	for _, c := range clusters {
+		text := line[c.start:c.end]
+		if c.level%2 == 1 {
+			// L4, brackets are mirrored in RTL runs
+			r, size := utf8.DecodeRuneInString(text)
+			if p, _ := bidi.LookupRune(r); p.IsBracket() {
+				text = bidi.ReverseString(string(r)) + text[size:]
+			}
+		}
+		for k := 0; k < len(text); k++ {
+			offset := c.start + k
+			if offset >= c.end {
+				offset = c.end - 1
+			}
+			bl.logical = append(bl.logical, offset)
+		}
+		visual = append(visual, text...)
+	}
+	bl.Visual = string(visual)
+
+	return bl
+}
+
+// Ranges returns the pieces of Visual that bytes [start, end) of the
+// logical line ended up in, from left to right. A range that crosses a
+// direction change can end up in more than one piece.
+func (bl *BidiLine) Ranges(start, end int) []Span {
+	var result []Span
+	in := false
+	for i, offset := range bl.logical {
+		inside := offset >= start && offset < end
+		switch {
+		case inside && !in:
+			result = append(result, Span{Start: i, End: i + 1})
+		case inside:
+			result[len(result)-1].End = i + 1
+		}
+		in = inside
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestBidiVisualOrder(t *testing.T) {
+	type test struct {
+		in  string
+		rtl bool
+		out string
+	}
+
+	tests := []test{
+		{in: "plain old text", rtl: false, out: "plain old text"},
+		{in: "שלום עולם", rtl: true, out: "םלוע םולש"},
+		{in: "hello עולם 123 שלום.", rtl: false, out: "hello םולש 123 םלוע."},
+		{in: "שלום hello world!", rtl: true, out: "!hello world םולש"},
+		{in: "שלום (עולם)", rtl: true, out: "(םלוע) םולש"},
+		{in: "שלום עולם ", rtl: true, out: " םלוע םולש"},
+	}
+
+	for ntest, tt := range tests {
+		levels, rtl := bidiLevels(tt.in)
+		bl := NewBidiLine(tt.in, levels, rtl)
+		if bl.RTL != tt.rtl || bl.Visual != tt.out {
+			const msg = "ntest: %d, got: %q (rtl %v), want %q (rtl %v)\n"
+			t.Errorf(msg, ntest, bl.Visual, bl.RTL, tt.out, tt.rtl)
+		}
+	}
+}
+
+func TestBidiRanges(t *testing.T) {
+	line := "hello עולם שלום"
+	levels, rtl := bidiLevels(line)
+	bl := NewBidiLine(line, levels, rtl)
+
+	// עולם is the first hebrew word, so it ends up last on the screen
+	start := len("hello ")
+	end := start + len("עולם")
+	ranges := bl.Ranges(start, end)
+	if len(ranges) != 1 {
+		t.Fatalf("got %v, want one range", ranges)
+	}
+	if got := bl.Visual[ranges[0].Start:ranges[0].End]; got != "םלוע" {
+		t.Errorf("got %q, want %q", got, "םלוע")
+	}
+	if ranges[0].End != len(line) {
+		t.Errorf("got %v, want it at the end of the line", ranges[0])
+	}
+
+	// across the direction change
+	if got := bl.Ranges(0, end); len(got) != 2 {
+		t.Errorf("got %v, want two ranges", got)
+	}
+}
+
+func TestDocumentRTLLayout(t *testing.T) {
+	regular, err := ParseFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	font := NewFontFamily(regular)
+	doc := NewDocument("test", "left to right\nמימין לשמאל", 400, 10, nil)
+	if len(doc.Lines) != 2 {
+		t.Fatalf("got lines %q", doc.Lines)
+	}
+
+	if x := doc.LineX(0, font, 18); x != 10 {
+		t.Errorf("ltr line starts at %d, want 10", x)
+	}
+	bl := doc.Bidi(1)
+	if !bl.RTL {
+		t.Fatalf("second line should be rtl")
+	}
+	right := doc.LineX(1, font, 18) + doc.Layout(1, font, 18).Width.Round()
+	if right != 10+doc.Width {
+		t.Errorf("rtl line ends at %d, want %d", right, 10+doc.Width)
+	}
+
+	// the first word of an rtl line is the one on the right
+	words := doc.Words(1)
+	first, _, _ := doc.RangeX(1, words[0].Start, words[0].End, font, 18)
+	second, _, _ := doc.RangeX(1, words[1].Start, words[1].End, font, 18)
+	if first <= second {
+		t.Errorf("first word at %d, second at %d", first, second)
+	}
+}
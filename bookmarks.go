@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+)
+
+// what we show for each bookmark in the bookmark panel
+func BookmarkLabels(doc *Document, bms []Bookmark) []string {
+	labels := make([]string, len(bms))
+	for i, bm := range bms {
+		percent := 0
+		if len(doc.Text) > 0 {
+			percent = bm.Start * 100 / len(doc.Text)
+		}
+		labels[i] = fmt.Sprintf("%3d%% %s", percent, bm.Name)
+	}
+	return labels
+}
+
+func NewBookmark(doc *Document, name string, start, end int) Bookmark {
+	if name == "" {
+		name = doc.Excerpt(start, 20)
+	}
+	return Bookmark{Name: name, Start: start, End: end}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Start and End are byte offsets of the sentence in File
+type ConcordanceHit struct {
+	File  string
+	Start int
+	End   int
+}
+
+// what we store for every indexed file, so that we know when
+// it has to be reindexed and which words to remove when it does
+type ConcordanceFile struct {
+	ModTime int64
+	Size    int64
+	Words   []string
+}
+
+// returns [start, end) byte offsets of every sentence in text
+func SplitSentences(text string) [][2]int {
+	var result [][2]int
+
+	add := func(start, end int) {
+		for start < end {
+			r, size := utf8.DecodeRuneInString(text[start:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			start += size
+		}
+		for end > start {
+			r, size := utf8.DecodeLastRuneInString(text[:end])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			end -= size
+		}
+		if start < end {
+			result = append(result, [2]int{start, end})
+		}
+	}
+
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		next := i + size
+
+		switch {
+		case r == '\n' && strings.HasPrefix(text[next:], "\n"), r == '\n' && strings.HasPrefix(text[next:], "\r\n"):
+			// blank line, paragraph ends no matter what
+			add(start, i)
+			start = next
+		case r == '.' || r == '!' || r == '?' || r == '…':
+			// swallow "?!", "..." and closing quotes/brackets
+			for next < len(text) {
+				r, size := utf8.DecodeRuneInString(text[next:])
+				if !strings.ContainsRune(".!?…\"'”’»)]", r) {
+					break
+				}
+				next += size
+			}
+			r, _ := utf8.DecodeRuneInString(text[next:])
+			if next == len(text) || unicode.IsSpace(r) {
+				add(start, next)
+				start = next
+			}
+		}
+		i = next
+	}
+	add(start, len(text))
+
+	return result
+}
+
+// the key a word is stored under in the index
+func concordanceKey(word string) string {
+	return FoldCase(strings.Trim(word, wordPunctuation))
+}
+
+// returns every distinct key in the sentence
+func SentenceWords(sentence string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, w := range strings.Fields(sentence) {
+		if AllNonAlpha(w) {
+			continue
+		}
+		key := concordanceKey(w)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, key)
+	}
+	return result
+}
+
+// reads the sentences back from the files, cache is shared between
+// calls so that we read every file only once. Sentences that can't be
+// found anymore come back as "" so that the result lines up with hits.
+func ConcordanceSentences(dir string, hits []ConcordanceHit, cache map[string]string) ([]string, error) {
+	result := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		text, ok := cache[hit.File]
+		if !ok {
+			data, err := ioutil.ReadFile(dir + hit.File)
+			if err != nil {
+				return result, err
+			}
+			text = string(data)
+			cache[hit.File] = text
+		}
+		if hit.End > len(text) || hit.Start > hit.End {
+			// the file changed since we indexed it
+			result = append(result, "")
+			continue
+		}
+		sentence := strings.Join(strings.Fields(text[hit.Start:hit.End]), " ")
+		result = append(result, sentence)
+	}
+	return result, nil
+}
+
+// lines for the concordance panel, every hit gets its file name
+// and then the sentence wrapped to fit
+func ConcordanceLines(hits []ConcordanceHit, sentences []string, width, fontW int) []string {
+	var result []string
+	for i := range sentences {
+		if sentences[i] == "" {
+			continue
+		}
+		result = append(result, fmt.Sprintf("[%s]", hits[i].File))
+		result = append(result, WrapLines(sentences[i], width, fontW)...)
+	}
+	return result
+}
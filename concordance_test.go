@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSplitSentences(t *testing.T) {
+	type test struct {
+		in  string
+		out []string
+	}
+
+	tests := []test{
+		{in: "", out: nil},
+		{in: "One. Two!", out: []string{"One.", "Two!"}},
+		{in: "Is it?! Yes...  no", out: []string{"Is it?!", "Yes...", "no"}},
+		{in: "He said \"stop.\" Then left.", out: []string{"He said \"stop.\"", "Then left."}},
+		{in: "Mr.Smith 3.14 is pi.", out: []string{"Mr.Smith 3.14 is pi."}},
+		{in: "A heading\n\nA paragraph\nover two lines.", out: []string{"A heading", "A paragraph\nover two lines."}},
+	}
+
+	for ntest, tt := range tests {
+		spans := SplitSentences(tt.in)
+		if len(spans) != len(tt.out) {
+			const msg = "ntest: %d, got %d sentences, want %d (%v)\n"
+			t.Errorf(msg, ntest, len(spans), len(tt.out), spans)
+			continue
+		}
+		for i, span := range spans {
+			if got := tt.in[span[0]:span[1]]; got != tt.out[i] {
+				const msg = "ntest: %d, got: %q, want %q\n"
+				t.Errorf(msg, ntest, got, tt.out[i])
+			}
+		}
+	}
+}
+
+func TestDBBuildConcordanceIncremental(t *testing.T) {
+	dir := t.TempDir() + string(filepath.Separator)
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), FILE_MODE_RW, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	write := func(name, text string, mod time.Time) {
+		if err := ioutil.WriteFile(dir+name, []byte(text), FILE_MODE_RW); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dir+name, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(word string) int {
+		hits, err := DBConcordance(db, word, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(hits)
+	}
+
+	then := time.Now().Add(-time.Hour)
+	write("a.txt", "The cat sat. A dog ran.", then)
+	write("b.txt", "Another cat! No dogs here.", then)
+
+	if n, err := DBBuildConcordance(db, dir); err != nil || n != 2 {
+		t.Fatalf("first build: indexed %d, err %v", n, err)
+	}
+	if got := count("Cat"); got != 2 {
+		t.Errorf("cat: got %d hits, want 2", got)
+	}
+
+	if n, _ := DBBuildConcordance(db, dir); n != 0 {
+		t.Errorf("nothing changed but %d files were reindexed", n)
+	}
+
+	write("a.txt", "No felines anymore. Only a dog.", then.Add(time.Minute))
+	if n, _ := DBBuildConcordance(db, dir); n != 1 {
+		t.Errorf("one file changed but %d files were reindexed", n)
+	}
+	if got := count("cat"); got != 1 {
+		t.Errorf("cat after change: got %d hits, want 1", got)
+	}
+
+	os.Remove(dir + "b.txt")
+	DBBuildConcordance(db, dir)
+	if got := count("cat"); got != 0 {
+		t.Errorf("cat after delete: got %d hits, want 0", got)
+	}
+
+	hits, _ := DBConcordance(db, "dog", 0)
+	sentences, err := ConcordanceSentences(dir, hits, make(map[string]string))
+	if err != nil || len(sentences) != 1 || sentences[0] != "Only a dog." {
+		t.Errorf("dog: got %q, err %v", sentences, err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"image"
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	maxDamageRects  = 32 // past this we upload the box around all of them
+	damageHistory   = 20 // frames the debug overlay keeps showing a rect
+	fullDamageRatio = 2  // if more than 1/2 of the image changed, upload all of it
+)
+
+// Damage keeps track of what changed in an image since it was last
+// uploaded to its texture, so that we don't send 640x480 pixels to the
+// GPU because one word changed color.
+type Damage struct {
+	bounds image.Rectangle
+	rects  []image.Rectangle
+	full   bool
+
+	history [][]image.Rectangle // what was uploaded, newest first
+}
+
+// NewDamage starts out with everything damaged, nothing was uploaded yet
+func NewDamage(bounds image.Rectangle) *Damage {
+	return &Damage{bounds: bounds, full: true}
+}
+
+// Reset is for when the image itself was replaced (the window got resized)
+func (d *Damage) Reset(bounds image.Rectangle) {
+	d.bounds = bounds
+	d.rects = d.rects[:0]
+	d.full = true
+}
+
+func area(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
+
+// Add marks rects as changed. Rects that are close to each other (words
+// on the same line) are merged as long as that doesn't upload much more
+// than what actually changed.
+func (d *Damage) Add(rects ...image.Rectangle) {
+	if d.full {
+		return
+	}
+	for _, r := range rects {
+		r = r.Intersect(d.bounds)
+		if r.Empty() {
+			continue
+		}
+		for merged := true; merged; {
+			merged = false
+			for i, old := range d.rects {
+				u := old.Union(r)
+				if area(u) <= 2*(area(old)+area(r)) || old.Overlaps(r) {
+					r = u
+					d.rects = append(d.rects[:i], d.rects[i+1:]...)
+					merged = true
+					break
+				}
+			}
+		}
+		d.rects = append(d.rects, r)
+	}
+
+	total := 0
+	for _, r := range d.rects {
+		total += area(r)
+	}
+	switch {
+	case total*fullDamageRatio > area(d.bounds):
+		d.full = true
+		d.rects = d.rects[:0]
+	case len(d.rects) > maxDamageRects:
+		var box image.Rectangle
+		for _, r := range d.rects {
+			box = box.Union(r)
+		}
+		d.rects = append(d.rects[:0], box)
+	}
+}
+
+// AddAll marks the whole image as changed
+func (d *Damage) AddAll() {
+	d.full = true
+	d.rects = d.rects[:0]
+}
+
+// Rects is what Upload would send right now
+func (d *Damage) Rects() []image.Rectangle {
+	if d.full {
+		return []image.Rectangle{d.bounds}
+	}
+	return d.rects
+}
+
+// Empty is true when there's nothing to upload
+func (d *Damage) Empty() bool {
+	return !d.full && len(d.rects) == 0
+}
+
+// Upload sends the damaged parts of img to tex and forgets about them
+func (d *Damage) Upload(tex *sdl.Texture, img *image.RGBA) error {
+	rects := append([]image.Rectangle(nil), d.Rects()...)
+	d.full = false
+	d.rects = d.rects[:0]
+
+	if len(d.history) >= damageHistory {
+		d.history = d.history[:damageHistory-1]
+	}
+	d.history = append([][]image.Rectangle{rects}, d.history...)
+
+	for _, r := range rects {
+		rect := sdl.Rect{X: int32(r.Min.X), Y: int32(r.Min.Y), W: int32(r.Dx()), H: int32(r.Dy())}
+		pixels := unsafe.Pointer(&img.Pix[img.PixOffset(r.Min.X, r.Min.Y)])
+		if err := tex.Update(&rect, pixels, img.Stride); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DrawDebug outlines what was uploaded in the last few frames, the older
+// it is the more it fades out
+func (d *Damage) DrawDebug(renderer *sdl.Renderer) {
+	for age := len(d.history) - 1; age >= 0; age-- {
+		if len(d.history[age]) == 0 {
+			continue
+		}
+		rects := make([]sdl.Rect, len(d.history[age]))
+		for i, r := range d.history[age] {
+			rects[i] = sdl.Rect{X: int32(r.Min.X), Y: int32(r.Min.Y), W: int32(r.Dx()), H: int32(r.Dy())}
+		}
+		alpha := uint8(255 - age*255/damageHistory)
+		renderer.SetDrawColor(255, 0, 0, alpha/3)
+		renderer.FillRects(rects)
+		renderer.SetDrawColor(255, 0, 0, alpha)
+		renderer.DrawRects(rects)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestDamageAdd(t *testing.T) {
+	bounds := image.Rect(0, 0, 640, 480)
+
+	type test struct {
+		in  []image.Rectangle
+		out []image.Rectangle
+	}
+
+	tests := []test{
+		// words on the same line end up as one strip
+		{
+			in:  []image.Rectangle{image.Rect(10, 22, 40, 25), image.Rect(46, 22, 80, 25)},
+			out: []image.Rectangle{image.Rect(10, 22, 80, 25)},
+		},
+		// but not with the line below
+		{
+			in:  []image.Rectangle{image.Rect(10, 22, 40, 25), image.Rect(10, 40, 40, 43)},
+			out: []image.Rectangle{image.Rect(10, 22, 40, 25), image.Rect(10, 40, 40, 43)},
+		},
+		// clipped to the image, nothing left of this one
+		{
+			in:  []image.Rectangle{image.Rect(-20, -20, -10, -10), image.Rect(630, 470, 700, 500)},
+			out: []image.Rectangle{image.Rect(630, 470, 640, 480)},
+		},
+		// most of the image, might as well send all of it
+		{
+			in:  []image.Rectangle{image.Rect(0, 0, 640, 300)},
+			out: []image.Rectangle{bounds},
+		},
+	}
+
+	for ntest, tt := range tests {
+		d := NewDamage(bounds)
+		d.full = false // as if it was just uploaded
+		d.Add(tt.in...)
+		got := d.Rects()
+		if len(got) != len(tt.out) {
+			t.Errorf("ntest: %d, got: %v, want %v\n", ntest, got, tt.out)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.out[i] {
+				t.Errorf("ntest: %d, got: %v, want %v\n", ntest, got, tt.out)
+				break
+			}
+		}
+	}
+}
+
+func TestDamageTooManyRects(t *testing.T) {
+	d := NewDamage(image.Rect(0, 0, 640, 480))
+	d.full = false
+	for i := 0; i < maxDamageRects+1; i++ {
+		d.Add(image.Rect(10*i, 12*i, 10*i+2, 12*i+2)) // a diagonal, none of them merge
+	}
+	got := d.Rects()
+	want := image.Rect(0, 0, 10*maxDamageRects+2, 12*maxDamageRects+2)
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	d.Reset(image.Rect(0, 0, 800, 600))
+	if got := d.Rects(); len(got) != 1 || got[0] != image.Rect(0, 0, 800, 600) {
+		t.Errorf("after a reset got %v", got)
+	}
+}
@@ -2,8 +2,14 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
@@ -28,8 +34,21 @@ func DBInit(db *bolt.DB, mk DBEntry) error {
 		if err != nil {
 			return fmt.Errorf("Failed to create bucket: %v", err)
 		}
+		// what the user toggled stays the way they left it
+		toggled := tx.Bucket([]byte("Toggled"))
 		for k, _ := range mk {
-			if bucket.Get([]byte(k)) == nil { // don't override key/val if exists
+			if old := bucket.Get([]byte(k)); old != nil {
+				// a name we only find out about now, from a new text or
+				// because the scan got better, is ignored like a new one
+				if !mk[k].Proper || !bytes.HasPrefix(old, []byte("B_")) ||
+					(toggled != nil && toggled.Get([]byte(k)) != nil) {
+					continue
+				}
+				promoted := append([]byte("I"), old[1:]...)
+				if err = bucket.Put([]byte(k), promoted); err != nil {
+					return fmt.Errorf("Failed to update '%s': '%v'", k, err)
+				}
+			} else { // don't override key/val if exists
 				bt := [][]byte{
 					[]byte(mk[k].Value),
 					[]byte(mk[k].Tags[0]),
@@ -44,6 +63,25 @@ func DBInit(db *bolt.DB, mk DBEntry) error {
 				}
 			}
 		}
+
+		// surface forms are merged with what we already have, since
+		// every text adds new ones to the same lemma
+		forms, err := tx.CreateBucketIfNotExists([]byte("WordForms"))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+		for k, v := range mk {
+			merged := &DBVal{}
+			if old := forms.Get([]byte(k)); old != nil {
+				merged.Forms = strings.Fields(string(old))
+			}
+			for _, f := range v.Forms {
+				merged.AddForm(f)
+			}
+			if err = forms.Put([]byte(k), []byte(strings.Join(merged.Forms, " "))); err != nil {
+				return fmt.Errorf("Failed to insert forms of '%s': '%v'", k, err)
+			}
+		}
 		return nil
 	})
 	if err != nil {
@@ -52,6 +90,117 @@ func DBInit(db *bolt.DB, mk DBEntry) error {
 	return nil
 }
 
+// DBCountUnknown counts the words of mk that aren't known yet, the ones
+// that are ignored ("I", names and what the user toggled) don't count.
+// Call it after DBInit, a word that's not in the db is unknown.
+func DBCountUnknown(db *bolt.DB, mk DBEntry) (int, error) {
+	count := 0
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("TestWords"))
+		for k := range mk {
+			var val []byte
+			if bucket != nil {
+				val = bucket.Get([]byte(k))
+			}
+			if !bytes.HasPrefix(val, []byte("I_")) {
+				count++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("bbolt db.View in DBCountUnknown failed '%v'", err)
+	}
+	return count, nil
+}
+
+// the surface forms we've seen for lemma, in the order we saw them
+func DBForms(db *bolt.DB, lemma string) ([]string, error) {
+	var result []string
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("WordForms"))
+		if bucket == nil {
+			return nil
+		}
+		result = strings.Fields(string(bucket.Get([]byte(lemma))))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bbolt db.View in DBForms failed '%v'", err)
+	}
+	return result, nil
+}
+
+// DBMigrateLemmaKeys moves the words of text that are still under the
+// form they had in the text (from before entries were keyed by lemma) to
+// the key they have now, mk is GetUniqueWords of the text. The form goes
+// into WordForms, when the lemma is already there its entry stays. It's
+// done once for every text and language, "Migrations" remembers which.
+func DBMigrateLemmaKeys(db *bolt.DB, text, lang string, mk DBEntry) (int, error) {
+	moved := 0
+	err := db.Update(func(tx *bolt.Tx) error {
+		migrations, err := tx.CreateBucketIfNotExists([]byte("Migrations"))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+		done := []byte("lemmas " + text)
+		if string(migrations.Get(done)) == lang {
+			return nil
+		}
+		words := tx.Bucket([]byte("TestWords"))
+		if words == nil { // nothing saved yet, nothing to move
+			return migrations.Put(done, []byte(lang))
+		}
+		forms, err := tx.CreateBucketIfNotExists([]byte("WordForms"))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+		toggled, err := tx.CreateBucketIfNotExists([]byte("Toggled"))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+
+		for key, v := range mk {
+			for _, form := range v.Forms {
+				for _, old := range []string{form, FoldCase(form)} {
+					val := words.Get([]byte(old))
+					if _, isKey := mk[old]; isKey || val == nil {
+						continue // an entry of its own now
+					}
+					if words.Get([]byte(key)) == nil {
+						if err = words.Put([]byte(key), append([]byte(nil), val...)); err != nil {
+							return fmt.Errorf("Failed to insert '%s': '%v'", key, err)
+						}
+						if prev := toggled.Get([]byte(old)); prev != nil {
+							if err = toggled.Put([]byte(key), append([]byte(nil), prev...)); err != nil {
+								return fmt.Errorf("Failed to insert '%s': '%v'", key, err)
+							}
+						}
+					}
+					if err = words.Delete([]byte(old)); err != nil {
+						return fmt.Errorf("Failed to delete '%s': '%v'", old, err)
+					}
+					if err = toggled.Delete([]byte(old)); err != nil {
+						return fmt.Errorf("Failed to delete '%s': '%v'", old, err)
+					}
+
+					merged := &DBVal{Forms: strings.Fields(string(forms.Get([]byte(key))))}
+					merged.AddForm(form)
+					if err = forms.Put([]byte(key), []byte(strings.Join(merged.Forms, " "))); err != nil {
+						return fmt.Errorf("Failed to insert forms of '%s': '%v'", key, err)
+					}
+					moved++
+				}
+			}
+		}
+		return migrations.Put(done, []byte(lang))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("bbolt db.Update in DBMigrateLemmaKeys failed '%v'", err)
+	}
+	return moved, nil
+}
+
 func DBInsert(db *bolt.DB, k string) error {
 	err := db.Update(func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists([]byte("TestWords"))
@@ -90,3 +239,516 @@ func DBView(db *bolt.DB, find string) ([]byte, error) {
 	}
 	return result, nil
 }
+
+// DBToggleIgnored marks key ignored ("I", the way names are) or not, it
+// returns whether it's ignored now. What it was before is kept in
+// "Toggled" so that toggling again gives back a punctuated word ("A") or
+// a name that was ignored to begin with.
+func DBToggleIgnored(db *bolt.DB, key string) (bool, error) {
+	var ignored bool
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("TestWords"))
+		if bucket == nil {
+			return fmt.Errorf("Failed to find bucket")
+		}
+		toggled, err := tx.CreateBucketIfNotExists([]byte("Toggled"))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+		val := bucket.Get([]byte(key))
+		if val == nil {
+			return fmt.Errorf("'%s' is not in the database", key)
+		}
+		fields := bytes.SplitN(val, []byte("_"), 2)
+		was := string(fields[0])
+
+		if prev := toggled.Get([]byte(key)); prev != nil {
+			fields[0] = append([]byte(nil), prev...)
+			err = toggled.Delete([]byte(key))
+		} else {
+			if was == "I" {
+				fields[0] = []byte("B")
+			} else {
+				fields[0] = []byte("I")
+			}
+			err = toggled.Put([]byte(key), []byte(was))
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to update '%s': '%v'", key, err)
+		}
+		ignored = string(fields[0]) == "I"
+		return bucket.Put([]byte(key), bytes.Join(fields, []byte("_")))
+	})
+	if err != nil {
+		return false, fmt.Errorf("bbolt db.Update in DBToggleIgnored failed '%v'", err)
+	}
+	return ignored, nil
+}
+
+func DBSavePosition(db *bolt.DB, text string, offset int) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("Positions"))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+		if err = bucket.Put([]byte(text), []byte(strconv.Itoa(offset))); err != nil {
+			return fmt.Errorf("Failed to insert '%s': '%v'", text, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bbolt db.Update in DBSavePosition failed '%v'", err)
+	}
+	return nil
+}
+
+// returns 0 if we haven't seen this text before
+func DBLoadPosition(db *bolt.DB, text string) (int, error) {
+	var offset int
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("Positions"))
+		if bucket == nil {
+			return nil
+		}
+		val := bucket.Get([]byte(text))
+		if val == nil {
+			return nil
+		}
+		n, err := strconv.Atoi(string(val))
+		if err != nil {
+			return fmt.Errorf("Bad position for '%s': %v", text, err)
+		}
+		offset = n
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("bbolt db.View in DBLoadPosition failed '%v'", err)
+	}
+	return offset, nil
+}
+
+// we only keep the last time a text was opened, so reopening
+// a text moves it to the top instead of adding a duplicate
+func DBAddRecent(db *bolt.DB, text string) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("History"))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+		stamp := strconv.FormatInt(time.Now().UnixNano(), 10)
+		if err = bucket.Put([]byte(text), []byte(stamp)); err != nil {
+			return fmt.Errorf("Failed to insert '%s': '%v'", text, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bbolt db.Update in DBAddRecent failed '%v'", err)
+	}
+	return nil
+}
+
+// most recently opened first, max <= 0 means everything
+func DBRecentTexts(db *bolt.DB, max int) ([]string, error) {
+	type recent struct {
+		text  string
+		stamp int64
+	}
+	var all []recent
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("History"))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			stamp, err := strconv.ParseInt(string(v), 10, 64)
+			if err != nil {
+				return fmt.Errorf("Bad timestamp for '%s': %v", k, err)
+			}
+			all = append(all, recent{text: string(k), stamp: stamp})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bbolt db.View in DBRecentTexts failed '%v'", err)
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].stamp > all[j].stamp })
+	if max > 0 && len(all) > max {
+		all = all[:max]
+	}
+
+	result := make([]string, len(all))
+	for i := range all {
+		result[i] = all[i].text
+	}
+	return result, nil
+}
+
+// bookmarks live in a bucket per text inside of "Bookmarks", keyed by
+// where they are like highlights, the name is only a label. Two of them
+// can have the same name and a bookmark on a blank line has none.
+func bookmarkKey(bm Bookmark) []byte {
+	return []byte(fmt.Sprintf("%010d", bm.Start))
+}
+
+func DBSaveBookmark(db *bolt.DB, text string, bm Bookmark) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte("Bookmarks"))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+		bucket, err := root.CreateBucketIfNotExists([]byte(text))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+		val, err := json.Marshal(bm)
+		if err != nil {
+			return fmt.Errorf("Failed to encode '%s': %v", bm.Name, err)
+		}
+		if err = bucket.Put(bookmarkKey(bm), val); err != nil {
+			return fmt.Errorf("Failed to insert '%s': '%v'", bm.Name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bbolt db.Update in DBSaveBookmark failed '%v'", err)
+	}
+	return nil
+}
+
+func DBDeleteBookmark(db *bolt.DB, text string, bm Bookmark) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte("Bookmarks"))
+		if root == nil {
+			return nil
+		}
+		bucket := root.Bucket([]byte(text))
+		if bucket == nil {
+			return nil
+		}
+		// bookmarks saved before they were keyed by offset are under their name
+		if bucket.Get(bookmarkKey(bm)) == nil && bm.Name != "" {
+			return bucket.Delete([]byte(bm.Name))
+		}
+		return bucket.Delete(bookmarkKey(bm))
+	})
+	if err != nil {
+		return fmt.Errorf("bbolt db.Update in DBDeleteBookmark failed '%v'", err)
+	}
+	return nil
+}
+
+// sorted by where they are in the text, not by name
+func DBLoadBookmarks(db *bolt.DB, text string) ([]Bookmark, error) {
+	var result []Bookmark
+	err := db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte("Bookmarks"))
+		if root == nil {
+			return nil
+		}
+		bucket := root.Bucket([]byte(text))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var bm Bookmark
+			if err := json.Unmarshal(v, &bm); err != nil {
+				return fmt.Errorf("Failed to decode '%s': %v", k, err)
+			}
+			result = append(result, bm)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bbolt db.View in DBLoadBookmarks failed '%v'", err)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start < result[j].Start })
+	return result, nil
+}
+
+// keyed by "start_end" with zero padding so that ForEach gives them back
+// in the order they appear in the text
+func highlightKey(hl Highlight) []byte {
+	return []byte(fmt.Sprintf("%010d_%010d", hl.Start, hl.End))
+}
+
+// saving a highlight over the same range replaces it, that's how we
+// change the color or the note
+func DBSaveHighlight(db *bolt.DB, text string, hl Highlight) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte("Highlights"))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+		bucket, err := root.CreateBucketIfNotExists([]byte(text))
+		if err != nil {
+			return fmt.Errorf("Failed to create bucket: %v", err)
+		}
+		val, err := json.Marshal(hl)
+		if err != nil {
+			return fmt.Errorf("Failed to encode highlight: %v", err)
+		}
+		if err = bucket.Put(highlightKey(hl), val); err != nil {
+			return fmt.Errorf("Failed to insert '%s': '%v'", highlightKey(hl), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bbolt db.Update in DBSaveHighlight failed '%v'", err)
+	}
+	return nil
+}
+
+func DBDeleteHighlight(db *bolt.DB, text string, hl Highlight) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte("Highlights"))
+		if root == nil {
+			return nil
+		}
+		bucket := root.Bucket([]byte(text))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete(highlightKey(hl))
+	})
+	if err != nil {
+		return fmt.Errorf("bbolt db.Update in DBDeleteHighlight failed '%v'", err)
+	}
+	return nil
+}
+
+func DBLoadHighlights(db *bolt.DB, text string) ([]Highlight, error) {
+	var result []Highlight
+	err := db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte("Highlights"))
+		if root == nil {
+			return nil
+		}
+		bucket := root.Bucket([]byte(text))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var hl Highlight
+			if err := json.Unmarshal(v, &hl); err != nil {
+				return fmt.Errorf("Failed to decode '%s': %v", k, err)
+			}
+			result = append(result, hl)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bbolt db.View in DBLoadHighlights failed '%v'", err)
+	}
+	return result, nil
+}
+
+// DBBuildConcordance brings the index up to date with the .txt files in dir.
+// Files that didn't change since the last time (same size and mod time)
+// are skipped, so only the first run over a library is slow.
+func DBBuildConcordance(db *bolt.DB, dir string) (int, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	present := make(map[string]bool)
+	indexed := 0
+
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".txt") {
+			continue
+		}
+		name := info.Name()
+		present[name] = true
+
+		var old ConcordanceFile
+		known := false
+		err = db.View(func(tx *bolt.Tx) error {
+			files := tx.Bucket([]byte("ConcordanceFiles"))
+			if files == nil {
+				return nil
+			}
+			val := files.Get([]byte(name))
+			if val == nil {
+				return nil
+			}
+			known = true
+			return json.Unmarshal(val, &old)
+		})
+		if err != nil {
+			return indexed, fmt.Errorf("bbolt db.View in DBBuildConcordance failed '%v'", err)
+		}
+		if known && old.ModTime == info.ModTime().UnixNano() && old.Size == info.Size() {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(dir + name)
+		if err != nil {
+			return indexed, err
+		}
+
+		err = db.Update(func(tx *bolt.Tx) error {
+			if err := dbUnindexFile(tx, name); err != nil {
+				return err
+			}
+			return dbIndexFile(tx, name, string(data), info)
+		})
+		if err != nil {
+			return indexed, fmt.Errorf("bbolt db.Update in DBBuildConcordance failed '%v'", err)
+		}
+		indexed += 1
+	}
+
+	// files that were deleted from dir
+	var gone []string
+	err = db.View(func(tx *bolt.Tx) error {
+		files := tx.Bucket([]byte("ConcordanceFiles"))
+		if files == nil {
+			return nil
+		}
+		return files.ForEach(func(k, v []byte) error {
+			if !present[string(k)] {
+				gone = append(gone, string(k))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return indexed, fmt.Errorf("bbolt db.View in DBBuildConcordance failed '%v'", err)
+	}
+	for _, name := range gone {
+		err = db.Update(func(tx *bolt.Tx) error {
+			return dbUnindexFile(tx, name)
+		})
+		if err != nil {
+			return indexed, fmt.Errorf("bbolt db.Update in DBBuildConcordance failed '%v'", err)
+		}
+	}
+
+	return indexed, nil
+}
+
+// every word gets a bucket inside of "Concordance", keys in there are
+// "<file>\x00<sentence start>" so that all of a file's entries sit
+// next to each other and can be removed with a single Seek
+func concordanceEntryKey(file string, start int) []byte {
+	return []byte(fmt.Sprintf("%s\x00%010d", file, start))
+}
+
+func dbIndexFile(tx *bolt.Tx, name, text string, info os.FileInfo) error {
+	root, err := tx.CreateBucketIfNotExists([]byte("Concordance"))
+	if err != nil {
+		return fmt.Errorf("Failed to create bucket: %v", err)
+	}
+	files, err := tx.CreateBucketIfNotExists([]byte("ConcordanceFiles"))
+	if err != nil {
+		return fmt.Errorf("Failed to create bucket: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	entry := ConcordanceFile{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+
+	for _, span := range SplitSentences(text) {
+		for _, word := range SentenceWords(text[span[0]:span[1]]) {
+			bucket, err := root.CreateBucketIfNotExists([]byte(word))
+			if err != nil {
+				return fmt.Errorf("Failed to create bucket '%s': %v", word, err)
+			}
+			if err = bucket.Put(concordanceEntryKey(name, span[0]), []byte(strconv.Itoa(span[1]))); err != nil {
+				return fmt.Errorf("Failed to insert '%s': '%v'", word, err)
+			}
+			if !seen[word] {
+				seen[word] = true
+				entry.Words = append(entry.Words, word)
+			}
+		}
+	}
+
+	val, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("Failed to encode '%s': %v", name, err)
+	}
+	return files.Put([]byte(name), val)
+}
+
+func dbUnindexFile(tx *bolt.Tx, name string) error {
+	files := tx.Bucket([]byte("ConcordanceFiles"))
+	root := tx.Bucket([]byte("Concordance"))
+	if files == nil || root == nil {
+		return nil
+	}
+	val := files.Get([]byte(name))
+	if val == nil {
+		return nil
+	}
+
+	var old ConcordanceFile
+	if err := json.Unmarshal(val, &old); err != nil {
+		return fmt.Errorf("Failed to decode '%s': %v", name, err)
+	}
+
+	prefix := []byte(name + "\x00")
+	for _, word := range old.Words {
+		bucket := root.Bucket([]byte(word))
+		if bucket == nil {
+			continue
+		}
+		// collect first, deleting while we walk the cursor skips keys
+		var keys [][]byte
+		c := bucket.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		if k, _ := bucket.Cursor().First(); k == nil {
+			if err := root.DeleteBucket([]byte(word)); err != nil {
+				return err
+			}
+		}
+	}
+	return files.Delete([]byte(name))
+}
+
+// max <= 0 means every hit
+func DBConcordance(db *bolt.DB, word string, max int) ([]ConcordanceHit, error) {
+	var result []ConcordanceHit
+	err := db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte("Concordance"))
+		if root == nil {
+			return nil
+		}
+		bucket := root.Bucket([]byte(concordanceKey(word)))
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if max > 0 && len(result) >= max {
+				break
+			}
+			sep := bytes.LastIndexByte(k, 0)
+			if sep < 0 {
+				continue
+			}
+			start, err := strconv.Atoi(string(k[sep+1:]))
+			if err != nil {
+				return fmt.Errorf("Bad key '%q': %v", k, err)
+			}
+			end, err := strconv.Atoi(string(v))
+			if err != nil {
+				return fmt.Errorf("Bad value for '%q': %v", k, err)
+			}
+			result = append(result, ConcordanceHit{File: string(k[:sep]), Start: start, End: end})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bbolt db.View in DBConcordance failed '%v'", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func testDB(t *testing.T) *bolt.DB {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), FILE_MODE_RW, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestDBBookmarks(t *testing.T) {
+	const msg = "ntest: %d, got: %v, want %v\n"
+	db := testDB(t)
+
+	// the same name twice and one without a name (a blank line)
+	saved := []Bookmark{
+		{Name: "chapter", Start: 50, End: 50},
+		{Name: "chapter", Start: 10, End: 20},
+		{Name: "", Start: 30, End: 30},
+	}
+	for _, bm := range saved {
+		if err := DBSaveBookmark(db, "test.txt", bm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		del  *Bookmark
+		want []Bookmark
+	}{
+		{nil, []Bookmark{saved[1], saved[2], saved[0]}},
+		{&saved[0], []Bookmark{saved[1], saved[2]}},
+		{&saved[2], []Bookmark{saved[1]}},
+		{&saved[1], nil},
+	}
+	for i, test := range tests {
+		if test.del != nil {
+			if err := DBDeleteBookmark(db, "test.txt", *test.del); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := DBLoadBookmarks(db, "test.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf(msg, i, got, test.want)
+			continue
+		}
+		for j := range got {
+			if got[j] != test.want[j] {
+				t.Errorf(msg, i, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+// bookmarks used to be keyed by name, those still load and delete
+func TestDBBookmarksByName(t *testing.T) {
+	db := testDB(t)
+	old := Bookmark{Name: "old one", Start: 5, End: 5}
+	err := db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte("Bookmarks"))
+		if err != nil {
+			return err
+		}
+		bucket, err := root.CreateBucketIfNotExists([]byte("test.txt"))
+		if err != nil {
+			return err
+		}
+		val, err := json.Marshal(old)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(old.Name), val)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DBLoadBookmarks(db, "test.txt")
+	if err != nil || len(got) != 1 || got[0] != old {
+		t.Fatalf("got: %v (%v), want %v\n", got, err, old)
+	}
+	if err := DBDeleteBookmark(db, "test.txt", got[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = DBLoadBookmarks(db, "test.txt"); len(got) != 0 {
+		t.Errorf("got: %v, want none\n", got)
+	}
+}
+
+func TestDBToggleIgnored(t *testing.T) {
+	const msg = "ntest: %d, got: %q, want %q\n"
+	db := testDB(t)
+	mk := DBEntry{
+		"word":   {Value: "B", Tags: []string{"", "", ""}},
+		"word's": {Value: "A", Tags: []string{"", "", ""}},
+		"london": {Value: "I", Tags: []string{"", "", ""}},
+	}
+	if err := DBInit(db, mk); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want []string // after every toggle
+	}{
+		{"word", []string{"I", "B", "I", "B"}},
+		{"word's", []string{"I", "A", "I", "A"}},
+		{"london", []string{"B", "I", "B", "I"}},
+	}
+	for i, test := range tests {
+		for _, want := range test.want {
+			ignored, err := DBToggleIgnored(db, test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			val, _ := DBView(db, test.key)
+			if got := strings.SplitN(string(val), "_", 2)[0]; got != want || ignored != (want == "I") {
+				t.Errorf(msg, i, got, want)
+			}
+		}
+	}
+
+	if _, err := DBToggleIgnored(db, "nothing"); err == nil {
+		t.Errorf("toggled a word that isn't there\n")
+	}
+}
+
+func TestDBMigrateLemmaKeys(t *testing.T) {
+	const msg = "ntest: %d, got: %q, want %q\n"
+	db := testDB(t)
+	// the way the words were keyed before, as they are in the text
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("TestWords"))
+		if err != nil {
+			return err
+		}
+		for k, v := range map[string]string{"He": "I_d_e_f", "runs": "A_a_b_c", "ran": "B_d_e_f", "running": "B_d_e_f", "cat": "B_d_e_f"} {
+			if err := bucket.Put([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lem := &LookupLemmatizer{Table: map[string]string{"ran": "run"}, Fallback: EnglishStemmer{}}
+	mk := GetUniqueWords([]string{"He runs, she ran", "running is fun"}, lem, nil)
+	if n, err := DBMigrateLemmaKeys(db, "test.txt", "en", mk); err != nil || n != 4 {
+		t.Fatalf("got: %d moved (%v), want 4\n", n, err)
+	}
+
+	tests := []struct {
+		key   string
+		want  string
+		forms string
+	}{
+		{"he", "I_d_e_f", "He"},
+		{"run", "A_a_b_c", "runs ran running"},
+		{"cat", "B_d_e_f", ""},
+		{"He", "", ""},
+		{"runs", "", ""},
+		{"ran", "", ""},
+		{"running", "", ""},
+	}
+	for i, test := range tests {
+		val, _ := DBView(db, test.key)
+		if string(val) != test.want {
+			t.Errorf(msg, i, val, test.want)
+		}
+		forms, _ := DBForms(db, test.key)
+		if got := strings.Join(forms, " "); got != test.forms {
+			t.Errorf(msg, i, got, test.forms)
+		}
+	}
+
+	// once is enough
+	if n, err := DBMigrateLemmaKeys(db, "test.txt", "en", mk); err != nil || n != 0 {
+		t.Errorf("got: %d moved (%v) the second time, want 0\n", n, err)
+	}
+}
+
+// names found by a later scan are ignored too, unless the user already
+// decided about them, and ignored words aren't unknown
+func TestDBInitProper(t *testing.T) {
+	const msg = "ntest: %d, got: %q, want %q\n"
+	db := testDB(t)
+	before := DBEntry{
+		"london": {Value: "B", Tags: []string{"d", "e", "f"}},
+		"paris":  {Value: "B", Tags: []string{"d", "e", "f"}},
+		"rome":   {Value: "B", Tags: []string{"d", "e", "f"}},
+		"cat":    {Value: "B", Tags: []string{"d", "e", "f"}},
+		"berlin": {Value: "I", Tags: []string{"d", "e", "f"}, Proper: true},
+	}
+	if err := DBInit(db, before); err != nil {
+		t.Fatal(err)
+	}
+	// the user ignores paris and cat, and wants to learn berlin
+	for _, key := range []string{"paris", "cat", "berlin"} {
+		if _, err := DBToggleIgnored(db, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	after := DBEntry{
+		"london": {Value: "I", Tags: []string{"d", "e", "f"}, Proper: true},
+		"paris":  {Value: "I", Tags: []string{"d", "e", "f"}, Proper: true},
+		"cat":    {Value: "B", Tags: []string{"d", "e", "f"}},
+		"dog":    {Value: "B", Tags: []string{"d", "e", "f"}},
+		"berlin": {Value: "I", Tags: []string{"d", "e", "f"}, Proper: true},
+	}
+	if err := DBInit(db, after); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"london", "I_d_e_f"},
+		{"paris", "I_d_e_f"},
+		{"rome", "B_d_e_f"},
+		{"berlin", "B_d_e_f"}, // toggled by the user, stays
+		{"cat", "I_d_e_f"},
+		{"dog", "B_d_e_f"},
+	}
+	for i, test := range tests {
+		if val, _ := DBView(db, test.key); string(val) != test.want {
+			t.Errorf(msg, i, val, test.want)
+		}
+	}
+
+	// london, paris and cat are ignored, dog and berlin aren't
+	if n, err := DBCountUnknown(db, after); err != nil || n != 2 {
+		t.Errorf("got: %d unknown (%v), want 2\n", n, err)
+	}
+	if n, _ := DBCountUnknown(db, DBEntry{"new": {}}); n != 1 {
+		t.Errorf("got: %d unknown, want a word that's not in the db\n", n)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// seg can be nil, in which case words are split on spaces
+func NewDocument(name, text string, length int, font_w int, seg Segmenter) *Document {
+	if seg == nil {
+		seg = SpaceSegmenter{}
+	}
+	doc := &Document{Name: name, Text: text, Segmenter: seg, Left: pageMarginLeft}
+	doc.Rewrap(length, font_w)
+	return doc
+}
+
+// Rewrap wraps Text again to a new width, everything we keep as offsets
+// into Text (highlights, bookmarks) stays where it was but line numbers
+// don't. Returns false if the width didn't change.
+func (doc *Document) Rewrap(length int, font_w int) bool {
+	if doc.Lines != nil && length == doc.Width {
+		return false
+	}
+	doc.Lines = WrapLinesWith(doc.Text, length, font_w, doc.Segmenter)
+	doc.Offsets = LineOffsets(doc.Text, doc.Lines)
+	doc.Width = length
+	doc.bidi = nil
+	doc.layouts = nil
+	return true
+}
+
+// WrapLines hands us back substrings of the input (minus the newlines and
+// the empty lines), so we can find each one by searching forward from
+// where the previous one ended.
+func LineOffsets(input string, lines []string) []int {
+	offsets := make([]int, len(lines))
+	cursor := 0
+	for i, line := range lines {
+		found := strings.Index(input[cursor:], line)
+		if found < 0 { // should not happen, but don't move backwards if it does
+			offsets[i] = cursor
+			continue
+		}
+		offsets[i] = cursor + found
+		cursor = offsets[i] + len(line)
+	}
+	return offsets
+}
+
+// returns the index of the line that contains offset
+func OffsetToLine(offsets []int, offset int) int {
+	if len(offsets) == 0 || offset <= 0 {
+		return 0
+	}
+	line := sort.Search(len(offsets), func(i int) bool { return offsets[i] > offset }) - 1
+	if line < 0 {
+		return 0
+	}
+	return line
+}
+
+// the words on a line, as byte ranges into doc.Lines[line]
+func (doc *Document) Words(line int) []Span {
+	if line < 0 || line >= len(doc.Lines) {
+		return nil
+	}
+	return doc.Segmenter.Segment(doc.Lines[line])
+}
+
+// Bidi returns line n in visual order. The levels have to be resolved
+// for the whole paragraph, so we do every line of it at once and keep
+// them around.
+func (doc *Document) Bidi(n int) *BidiLine {
+	if len(doc.bidi) != len(doc.Lines) {
+		doc.bidi = make([]*BidiLine, len(doc.Lines))
+	}
+	if bl := doc.bidi[n]; bl != nil {
+		return bl
+	}
+
+	start := strings.LastIndexByte(doc.Text[:doc.Offsets[n]], '\n') + 1
+	end := len(doc.Text)
+	if i := strings.IndexByte(doc.Text[doc.Offsets[n]:], '\n'); i >= 0 {
+		end = doc.Offsets[n] + i
+	}
+	levels, rtl := bidiLevels(doc.Text[start:end])
+
+	first := n
+	for first > 0 && doc.Offsets[first-1] >= start {
+		first--
+	}
+	for m := first; m < len(doc.Lines) && doc.Offsets[m] < end; m++ {
+		from := doc.Offsets[m] - start
+		doc.bidi[m] = NewBidiLine(doc.Lines[m], levels[from:from+len(doc.Lines[m])], rtl)
+	}
+	return doc.bidi[n]
+}
+
+// Layout returns line n shaped, the layouts are kept until the font
+// or the font size changes
+func (doc *Document) Layout(n int, fam *FontFamily, fontSize float64) *LineLayout {
+	if doc.layoutFamily != fam || doc.layoutSize != fontSize || len(doc.layouts) != len(doc.Lines) {
+		doc.layouts = make([]*LineLayout, len(doc.Lines))
+		doc.layoutFamily = fam
+		doc.layoutSize = fontSize
+	}
+	if doc.layouts[n] == nil {
+		doc.layouts[n] = fam.LayoutLine(doc.Lines[n], doc.Bidi(n), doc.LineStyles(n), fontSize)
+	}
+	return doc.layouts[n]
+}
+
+// LineStyles returns the part of doc.Styles that falls on line n,
+// relative to the start of the line
+func (doc *Document) LineStyles(n int) []StyleSpan {
+	lineStart := doc.Offsets[n]
+	lineEnd := lineStart + len(doc.Lines[n])
+
+	var result []StyleSpan
+	for _, st := range doc.Styles {
+		if st.End <= lineStart || st.Start >= lineEnd {
+			continue
+		}
+		from, to := st.Start-lineStart, st.End-lineStart
+		if from < 0 {
+			from = 0
+		}
+		if to > len(doc.Lines[n]) {
+			to = len(doc.Lines[n])
+		}
+		result = append(result, StyleSpan{Start: from, End: to, Style: st.Style})
+	}
+	return result
+}
+
+// where line n starts on the screen, RTL lines are right aligned
+func (doc *Document) LineX(n int, fam *FontFamily, fontSize float64) int {
+	if !doc.Bidi(n).RTL {
+		return doc.Left
+	}
+	x := doc.Left + doc.Width - doc.Layout(n, fam, fontSize).Width.Round()
+	if x < doc.Left { // too long, let it stick out on the right
+		return doc.Left
+	}
+	return x
+}
+
+// RangeXs returns where bytes [start, end) of line n are on the screen,
+// one [x0, x1) pair for every piece of it, see LineLayout.Ranges
+func (doc *Document) RangeXs(n, start, end int, fam *FontFamily, fontSize float64) [][2]int {
+	x := doc.LineX(n, fam, fontSize)
+	result := doc.Layout(n, fam, fontSize).Ranges(start, end)
+	for i := range result {
+		result[i][0] += x
+		result[i][1] += x
+	}
+	return result
+}
+
+// same as RangeXs but one pair from the leftmost to the rightmost piece,
+// ok when =false the range isn't on the line
+func (doc *Document) RangeX(n, start, end int, fam *FontFamily, fontSize float64) (int, int, bool) {
+	xs := doc.RangeXs(n, start, end, fam, fontSize)
+	if len(xs) == 0 {
+		return 0, 0, false
+	}
+	return xs[0][0], xs[len(xs)-1][1], true
+}
+
+func (doc *Document) LineOffset(line int) int {
+	if line < 0 || len(doc.Offsets) == 0 {
+		return 0
+	}
+	if line >= len(doc.Offsets) {
+		return doc.Offsets[len(doc.Offsets)-1]
+	}
+	return doc.Offsets[line]
+}
+
+func (doc *Document) LineAt(offset int) int {
+	return OffsetToLine(doc.Offsets, offset)
+}
+
+// char is counted in runes from the start of the line, the same way
+// GetSelectedCharLen counts them
+func (doc *Document) CharOffset(line, char int) int {
+	if line < 0 || line >= len(doc.Lines) {
+		return doc.LineOffset(line)
+	}
+	text := doc.Lines[line]
+	index := 0
+	for ; char > 0 && index < len(text); char-- {
+		_, size := utf8.DecodeRuneInString(text[index:])
+		index += size
+	}
+	return doc.Offsets[line] + index
+}
+
+// turns a mouse selection (lines and chars) into a pair of offsets,
+// start is always <= end no matter which way we dragged
+func (doc *Document) SelectionRange(startLine, startChar, endLine, endChar int) (int, int) {
+	start := doc.CharOffset(startLine, startChar)
+	end := doc.CharOffset(endLine, endChar)
+	if end < start {
+		start, end = end, start
+	}
+	return start, end
+}
+
+// a short piece of text starting at offset, used as a default name
+// for bookmarks and as a preview in the panels
+func (doc *Document) Excerpt(offset int, max int) string {
+	if offset < 0 || offset >= len(doc.Text) {
+		return ""
+	}
+	text := strings.TrimSpace(doc.Text[offset:])
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:max]) + "..."
+}
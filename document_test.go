@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineOffsets(t *testing.T) {
+	input := "one two three four\n\nfive six seven\neight"
+	lines := WrapLines(input, 100, 10) // 9 chars per line
+
+	offsets := LineOffsets(input, lines)
+	if len(offsets) != len(lines) {
+		t.Fatalf("got %d offsets for %d lines", len(offsets), len(lines))
+	}
+
+	for i, line := range lines {
+		if !strings.HasPrefix(input[offsets[i]:], line) {
+			const msg = "ntest: %d, offset %d doesn't point at %q\n"
+			t.Errorf(msg, i, offsets[i], line)
+		}
+	}
+}
+
+func TestOffsetToLine(t *testing.T) {
+	offsets := []int{0, 10, 20, 35}
+
+	type test struct {
+		in  int
+		out int
+	}
+
+	tests := []test{
+		{in: -5, out: 0},
+		{in: 0, out: 0},
+		{in: 9, out: 0},
+		{in: 10, out: 1},
+		{in: 21, out: 2},
+		{in: 35, out: 3},
+		{in: 1000, out: 3},
+	}
+
+	for ntest, tt := range tests {
+		result := OffsetToLine(offsets, tt.in)
+		if result != tt.out {
+			const msg = "ntest: %d, got: %d, want %d\n"
+			t.Errorf(msg, ntest, result, tt.out)
+		}
+	}
+}
+
+// the whole point of storing offsets: the same offset has to land on
+// the line containing the same text no matter how wide the lines are
+func TestOffsetSurvivesRewrap(t *testing.T) {
+	input := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+	narrow := NewDocument("test", input, 100, 10, nil)
+	wide := NewDocument("test", input, 300, 10, nil)
+
+	offset := narrow.LineOffset(7)
+	line := wide.LineAt(offset)
+
+	start := wide.LineOffset(line)
+	end := start + len(wide.Lines[line])
+	if offset < start || offset >= end {
+		const msg = "offset %d not in [%d, %d) of the rewrapped line %d\n"
+		t.Errorf(msg, offset, start, end, line)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	input := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+	doc := NewDocument("test", input, 100, 10, nil)
+	font := NewFontFamily(testFont(t))
+	before := doc.Layout(3, font, 18)
+
+	if doc.Rewrap(100, 10) {
+		t.Errorf("same width shouldn't rewrap")
+	}
+	if !doc.Rewrap(300, 10) {
+		t.Fatalf("didn't rewrap")
+	}
+	want := NewDocument("test", input, 300, 10, nil)
+	if len(doc.Lines) != len(want.Lines) || doc.Width != 300 {
+		t.Errorf("got %d lines, want %d", len(doc.Lines), len(want.Lines))
+	}
+	// old layouts are for the old lines
+	if after := doc.Layout(3, font, 18); after == before || after.Width <= before.Width {
+		t.Errorf("layout wasn't redone, %v and %v", before.Width, after.Width)
+	}
+}
+
+func TestSelectionRange(t *testing.T) {
+	doc := NewDocument("test", "héllo wörld\nsecond line here", 400, 10, nil)
+
+	type test struct {
+		startLine, startChar int
+		endLine, endChar     int
+		out                  string
+	}
+
+	tests := []test{
+		{startLine: 0, startChar: 0, endLine: 0, endChar: 5, out: "héllo"},
+		{startLine: 0, startChar: 6, endLine: 0, endChar: 11, out: "wörld"},
+		{startLine: 0, startChar: 11, endLine: 0, endChar: 6, out: "wörld"}, // dragged backwards
+		{startLine: 0, startChar: 6, endLine: 1, endChar: 6, out: "wörld\nsecond"},
+		{startLine: 1, startChar: 12, endLine: 1, endChar: 100, out: "here"},
+	}
+
+	for ntest, tt := range tests {
+		start, end := doc.SelectionRange(tt.startLine, tt.startChar, tt.endLine, tt.endChar)
+		if doc.Text[start:end] != tt.out {
+			const msg = "ntest: %d, got: %q, want %q\n"
+			t.Errorf(msg, ntest, doc.Text[start:end], tt.out)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+
+	"github.com/golang/freetype"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// DisplayScale is how the window maps to real pixels. On a retina mac the
+// window is in points and the drawable is twice as big (Drawable == 2),
+// on windows and linux the window is in pixels and the display just has
+// more of them per inch, so there it's the DPI that tells us to scale.
+type DisplayScale struct {
+	Drawable float64 // drawable pixels per window coordinate
+	DPI      float64 // what we set freetype.Context to, 72 means 1 point is 1 pixel
+}
+
+// the DPI a display has when the OS doesn't scale anything
+const baseDPI = 96
+
+func displayScale(windowW, drawableW int32, ddpi float32) DisplayScale {
+	s := DisplayScale{Drawable: 1, DPI: 72}
+	if windowW > 0 && drawableW > windowW {
+		s.Drawable = float64(drawableW) / float64(windowW)
+		s.DPI = 72 * s.Drawable
+		return s // the OS already scaled, the DPI would count it twice
+	}
+	// physical DPI is never exact, 1.25x steps is what the OSes use anyway
+	factor := math.Round(float64(ddpi)/baseDPI*4) / 4
+	if factor > 1 {
+		s.DPI = 72 * factor
+	}
+	return s
+}
+
+// NewDisplayScale asks SDL about the display the window is on
+func NewDisplayScale(window *sdl.Window, renderer *sdl.Renderer) DisplayScale {
+	windowW, _ := window.GetSize()
+	drawableW, _, err := renderer.GetOutputSize()
+	if err != nil {
+		drawableW = windowW
+	}
+	var ddpi float32
+	if index, err := window.GetDisplayIndex(); err == nil {
+		ddpi, _, _, _ = sdl.GetDisplayDPI(index)
+	}
+	return displayScale(windowW, drawableW, ddpi)
+}
+
+// Factor is how many drawable pixels one of our "pixels" is, everything
+// we used to hard code for a 72 DPI window gets multiplied by it
+func (s DisplayScale) Factor() float64 {
+	return s.DPI / 72
+}
+
+func (s DisplayScale) Px(v int) int {
+	return int(math.Round(float64(v) * s.Factor()))
+}
+
+// ToDrawable turns window coordinates (what mouse events have) into
+// drawable ones (what we draw with)
+func (s DisplayScale) ToDrawable(x, y int32) (int32, int32) {
+	return int32(math.Round(float64(x) * s.Drawable)), int32(math.Round(float64(y) * s.Drawable))
+}
+
+// PixelSize is fontSize (in points) in pixels at the DPI ctx was set to,
+// this is the size we shape and rasterize at
+func PixelSize(ctx *freetype.Context, fontSize float64) float64 {
+	return float64(ctx.PointToFixed(fontSize)) / 64
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/freetype"
+)
+
+func TestDisplayScale(t *testing.T) {
+	type test struct {
+		windowW, drawableW int32
+		ddpi               float32
+		drawable, dpi      float64
+	}
+
+	tests := []test{
+		{windowW: 640, drawableW: 640, ddpi: 96, drawable: 1, dpi: 72},
+		{windowW: 640, drawableW: 640, ddpi: 0, drawable: 1, dpi: 72}, // SDL didn't know
+		{windowW: 640, drawableW: 640, ddpi: 80, drawable: 1, dpi: 72},
+		{windowW: 640, drawableW: 640, ddpi: 144, drawable: 1, dpi: 108},
+		{windowW: 640, drawableW: 640, ddpi: 141, drawable: 1, dpi: 108},  // a real laptop panel, close enough to 1.5
+		{windowW: 640, drawableW: 1280, ddpi: 220, drawable: 2, dpi: 144}, // retina, the dpi is already in drawable
+	}
+
+	for ntest, tt := range tests {
+		s := displayScale(tt.windowW, tt.drawableW, tt.ddpi)
+		if s.Drawable != tt.drawable || s.DPI != tt.dpi {
+			const msg = "ntest: %d, got: %+v, want %v %v\n"
+			t.Errorf(msg, ntest, s, tt.drawable, tt.dpi)
+		}
+	}
+
+	s := displayScale(640, 1280, 0)
+	if x, y := s.ToDrawable(100, 51); x != 200 || y != 102 {
+		t.Errorf("got %d, %d", x, y)
+	}
+	if s.Px(20) != 40 {
+		t.Errorf("got %d, want 40", s.Px(20))
+	}
+
+	// the same 18pt is twice as many pixels to shape at
+	ctx := freetype.NewContext()
+	ctx.SetDPI(s.DPI)
+	if got := PixelSize(ctx, 18); got != 36 {
+		t.Errorf("got %v, want 36", got)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gotext "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/shaping"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Font is a font file parsed twice, truetype for freetype.Context (line
+// metrics and the like) and go-text for shaping and glyph outlines.
+// freetype can only draw one rune at a time, which is fine for latin
+// but not for arabic or devanagari.
+type Font struct {
+	*truetype.Font
+	Fallbacks []*Font // tried in order for runes this font doesn't have
+
+	face   *gotext.Face
+	shaper shaping.HarfbuzzShaper
+	ras    vector.Rasterizer
+}
+
+func ParseFont(data []byte) (*Font, error) {
+	tt, err := truetype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	face, err := gotext.ParseTTF(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &Font{Font: tt, face: face}, nil
+}
+
+// LoadFontChain loads dir+name as the primary font and every other font
+// file in dir (except the ones in skip) as a fallback, in alphabetical
+// order. A fallback that can't be parsed is skipped, there's no reason to
+// not start because of it.
+func LoadFontChain(dir, name string, skip ...string) (*Font, error) {
+	data, err := ioutil.ReadFile(dir + name)
+	if err != nil {
+		return nil, err
+	}
+	font, err := ParseFont(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse '%s': %v", name, err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return font, err
+	}
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || e.Name() == name || (ext != ".ttf" && ext != ".otf") {
+			continue
+		}
+		if containsString(skip, e.Name()) {
+			continue
+		}
+		data, err := ioutil.ReadFile(dir + e.Name())
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fallback, err := ParseFont(data)
+		if err != nil {
+			fmt.Printf("skipping fallback font '%s': %v\n", e.Name(), err)
+			continue
+		}
+		font.Fallbacks = append(font.Fallbacks, fallback)
+	}
+	return font, nil
+}
+
+// FontFamily is the faces we pick from for styled text. Only Regular has
+// to be there, a style we don't have a face for is drawn with Regular.
+type FontFamily struct {
+	Regular    *Font
+	Bold       *Font
+	Italic     *Font
+	BoldItalic *Font
+	Mono       *Font
+}
+
+// a family with just the one face, for text that has no styles
+func NewFontFamily(regular *Font) *FontFamily {
+	return &FontFamily{Regular: regular}
+}
+
+// Face returns the font for style, code ignores bold and italic
+func (fam *FontFamily) Face(style FontStyle) *Font {
+	var face *Font
+	switch {
+	case style&StyleMono != 0:
+		face = fam.Mono
+	case style&StyleBold != 0 && style&StyleItalic != 0:
+		face = fam.BoldItalic
+		if face == nil {
+			face = fam.Bold
+		}
+		if face == nil {
+			face = fam.Italic
+		}
+	case style&StyleBold != 0:
+		face = fam.Bold
+	case style&StyleItalic != 0:
+		face = fam.Italic
+	}
+	if face == nil {
+		return fam.Regular
+	}
+	return face
+}
+
+// fontVariants are the file name suffixes we look for next to the
+// regular face, "Foo-Regular.ttf" (or "Foo.ttf") goes with "Foo-Bold.ttf"
+var fontVariants = []struct {
+	style    FontStyle
+	suffixes []string
+}{
+	{StyleBold, []string{"Bold"}},
+	{StyleItalic, []string{"Italic", "Oblique"}},
+	{StyleBold | StyleItalic, []string{"BoldItalic", "BoldOblique"}},
+}
+
+func fontVariantName(name, suffix string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(strings.TrimSuffix(name, ext), "-Regular")
+	return base + "-" + suffix + ext
+}
+
+// LoadFontFamily loads name and its bold and italic faces from dir, mono
+// is the font for code and can be "". Every face gets the fallbacks of the
+// regular one.
+func LoadFontFamily(dir, name, mono string) (*FontFamily, error) {
+	files := make(map[FontStyle]string)
+	var skip []string
+	for _, v := range fontVariants {
+		for _, suffix := range v.suffixes {
+			file := fontVariantName(name, suffix)
+			if _, err := os.Stat(dir + file); err == nil {
+				files[v.style] = file
+				skip = append(skip, file)
+				break
+			}
+		}
+	}
+	if mono != "" && mono != name {
+		files[StyleMono] = mono
+		skip = append(skip, mono)
+	}
+
+	regular, err := LoadFontChain(dir, name, skip...)
+	if regular == nil {
+		return nil, err
+	}
+	fam := NewFontFamily(regular)
+
+	for style, file := range files {
+		data, err := ioutil.ReadFile(dir + file)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		face, err := ParseFont(data)
+		if err != nil {
+			fmt.Printf("skipping font '%s': %v\n", file, err)
+			continue
+		}
+		face.Fallbacks = regular.Fallbacks
+		switch style {
+		case StyleBold:
+			fam.Bold = face
+		case StyleItalic:
+			fam.Italic = face
+		case StyleBold | StyleItalic:
+			fam.BoldItalic = face
+		case StyleMono:
+			fam.Mono = face
+		}
+	}
+	return fam, err
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// HasGlyph reports whether this font (not counting the fallbacks) has r
+func (f *Font) HasGlyph(r rune) bool {
+	_, ok := f.face.NominalGlyph(r)
+	return ok
+}
+
+// FontFor returns the first font in the chain that has r, runes that
+// nobody has end up with the primary font and its .notdef box
+func (f *Font) FontFor(r rune) *Font {
+	if f.HasGlyph(r) {
+		return f
+	}
+	for _, fallback := range f.Fallbacks {
+		if fallback.HasGlyph(r) {
+			return fallback
+		}
+	}
+	return f
+}
+
+// glyphBounds is the box of pixels glyph id covers with its origin at
+// dot, ok is false for glyphs that have nothing to draw (spaces)
+func (f *Font) glyphBounds(id gotext.GID, size float64, dot fixed.Point26_6) (image.Rectangle, bool) {
+	outline, ok := f.face.GlyphData(id).(gotext.GlyphOutline)
+	if !ok || len(outline.Segments) == 0 {
+		return image.Rectangle{}, false
+	}
+
+	scale := float32(size) / float32(f.face.Upem())
+	dotX := float32(dot.X) / 64
+	dotY := float32(dot.Y) / 64
+
+	// font units have y going up, we have it going down
+	minX, minY := float32(math.MaxFloat32), float32(math.MaxFloat32)
+	maxX, maxY := float32(-math.MaxFloat32), float32(-math.MaxFloat32)
+	for _, seg := range outline.Segments {
+		for _, p := range seg.ArgsSlice() {
+			x, y := dotX+p.X*scale, dotY-p.Y*scale
+			minX, maxX = minF32(minX, x), maxF32(maxX, x)
+			minY, maxY = minF32(minY, y), maxF32(maxY, y)
+		}
+	}
+	return image.Rect(
+		int(math.Floor(float64(minX))), int(math.Floor(float64(minY))),
+		int(math.Ceil(float64(maxX))), int(math.Ceil(float64(maxY))),
+	), true
+}
+
+// drawGlyph draws glyph id with its origin at dot, dst is only touched
+// where it overlaps the glyph
+func (f *Font) drawGlyph(dst draw.Image, src image.Image, id gotext.GID, size float64, dot fixed.Point26_6) {
+	bounds, ok := f.glyphBounds(id, size, dot)
+	if !ok {
+		return // spaces and bitmap glyphs
+	}
+	bounds = bounds.Intersect(dst.Bounds())
+	if bounds.Empty() {
+		return
+	}
+	outline := f.face.GlyphData(id).(gotext.GlyphOutline)
+
+	scale := float32(size) / float32(f.face.Upem())
+	dotX := float32(dot.X) / 64
+	dotY := float32(dot.Y) / 64
+
+	f.ras.Reset(bounds.Dx(), bounds.Dy())
+	f.ras.DrawOp = draw.Over
+	ox, oy := float32(bounds.Min.X), float32(bounds.Min.Y)
+	pt := func(p gotext.SegmentPoint) (float32, float32) {
+		return dotX + p.X*scale - ox, dotY - p.Y*scale - oy
+	}
+	for i, seg := range outline.Segments {
+		switch seg.Op {
+		case ot.SegmentOpMoveTo:
+			if i > 0 {
+				f.ras.ClosePath()
+			}
+			f.ras.MoveTo(pt(seg.Args[0]))
+		case ot.SegmentOpLineTo:
+			f.ras.LineTo(pt(seg.Args[0]))
+		case ot.SegmentOpQuadTo:
+			bx, by := pt(seg.Args[0])
+			cx, cy := pt(seg.Args[1])
+			f.ras.QuadTo(bx, by, cx, cy)
+		case ot.SegmentOpCubeTo:
+			bx, by := pt(seg.Args[0])
+			cx, cy := pt(seg.Args[1])
+			dx, dy := pt(seg.Args[2])
+			f.ras.CubeTo(bx, by, cx, cy, dx, dy)
+		}
+	}
+	f.ras.ClosePath()
+	f.ras.Draw(dst, bounds, src, bounds.Min)
+}
+
+func minF32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxF32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
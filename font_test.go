@@ -0,0 +1,188 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// a font that only has "0156789" with Go Regular behind it
+func testFontChain(t *testing.T) (*Font, *Font) {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/glyfTest.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, err := ParseFont(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallback := testFont(t)
+	primary.Fallbacks = []*Font{fallback}
+	return primary, fallback
+}
+
+func TestFontFor(t *testing.T) {
+	primary, fallback := testFontChain(t)
+
+	type test struct {
+		in  rune
+		out *Font
+	}
+
+	tests := []test{
+		{in: '5', out: primary},
+		{in: 'a', out: fallback},
+		{in: ' ', out: fallback},
+		{in: 'ש', out: primary}, // nobody has it, so it's tofu from the primary
+	}
+
+	for ntest, tt := range tests {
+		if result := primary.FontFor(tt.in); result != tt.out {
+			t.Errorf("ntest: %d, %q got the wrong font\n", ntest, tt.in)
+		}
+	}
+}
+
+func TestShapeFallback(t *testing.T) {
+	primary, fallback := testFontChain(t)
+
+	glyphs := primary.Shape("15 apples", 18, false)
+	if len(glyphs) != 9 {
+		t.Fatalf("got %d glyphs, want 9", len(glyphs))
+	}
+	for i, g := range glyphs {
+		want := fallback
+		if i < 2 {
+			want = primary
+		}
+		if g.Font != want {
+			t.Errorf("ntest: %d, glyph for %q came from the wrong font", i, "15 apples"[g.Start:g.End])
+		}
+		if g.ID == 0 {
+			t.Errorf("ntest: %d, got .notdef", i)
+		}
+	}
+
+	// widths come from the same chain
+	want := WidthOfString(primary, 18, "15") + WidthOfString(fallback, 18, " apples")
+	if got := WidthOfString(primary, 18, "15 apples"); got != want {
+		t.Errorf("got width %v, want %v", got, want)
+	}
+}
+
+func TestLoadFontChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fonts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir += string(filepath.Separator)
+
+	files := map[string][]byte{
+		"b-regular.ttf": goregular.TTF,
+		"a-other.ttf":   goregular.TTF,
+		"broken.otf":    []byte("not a font"),
+		"notes.txt":     []byte("not a font either"),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(dir+name, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	font, err := LoadFontChain(dir, "b-regular.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(font.Fallbacks) != 1 {
+		t.Errorf("got %d fallbacks, want 1", len(font.Fallbacks))
+	}
+
+	if _, err := LoadFontChain(dir, "missing.ttf"); err == nil {
+		t.Errorf("expected an error for a missing primary font")
+	}
+}
+
+func TestFontFamilyFace(t *testing.T) {
+	regular := testFont(t)
+	bold, err := ParseFont(gobold.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fam := &FontFamily{Regular: regular, Bold: bold}
+
+	type test struct {
+		in  FontStyle
+		out *Font
+	}
+
+	tests := []test{
+		{in: 0, out: regular},
+		{in: StyleBold, out: bold},
+		{in: StyleItalic, out: regular},
+		{in: StyleBold | StyleItalic, out: bold}, // closer than regular
+		{in: StyleMono | StyleBold, out: regular},
+	}
+
+	for ntest, tt := range tests {
+		if result := fam.Face(tt.in); result != tt.out {
+			t.Errorf("ntest: %d, style %d got the wrong face\n", ntest, tt.in)
+		}
+	}
+
+	// only "bold" is shaped with the bold face, and it's wider for it
+	line := "plain bold"
+	levels, rtl := bidiLevels(line)
+	bl := NewBidiLine(line, levels, rtl)
+	styled := fam.LayoutLine(line, bl, []StyleSpan{{Start: 6, End: 10, Style: StyleBold}}, 18)
+	plain := fam.LayoutLine(line, bl, nil, 18)
+	for i, g := range styled.Glyphs {
+		want := regular
+		if g.Start >= 6 {
+			want = bold
+		}
+		if g.Font != want {
+			t.Errorf("ntest: %d, glyph for %q has the wrong face", i, line[g.Start:g.End])
+		}
+	}
+	if styled.Width <= plain.Width {
+		t.Errorf("bold line is %v wide, plain one %v", styled.Width, plain.Width)
+	}
+}
+
+func TestLoadFontFamily(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fonts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir += string(filepath.Separator)
+
+	files := map[string][]byte{
+		"Go-Regular.ttf": goregular.TTF,
+		"Go-Bold.ttf":    gobold.TTF,
+		"Other.ttf":      goregular.TTF,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(dir+name, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fam, err := LoadFontFamily(dir, "Go-Regular.ttf", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fam.Bold == nil || fam.Italic != nil || fam.Mono != nil {
+		t.Errorf("got %+v", fam)
+	}
+	// the bold face is not a fallback, and it has the same fallbacks
+	if len(fam.Regular.Fallbacks) != 1 || len(fam.Bold.Fallbacks) != 1 {
+		t.Errorf("got %d and %d fallbacks, want 1", len(fam.Regular.Fallbacks), len(fam.Bold.Fallbacks))
+	}
+}
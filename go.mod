@@ -3,10 +3,12 @@ module gosdl
 go 1.19
 
 require (
+	github.com/go-text/typesetting v0.2.1
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/veandco/go-sdl2 v0.4.27
 	go.etcd.io/bbolt v1.3.6
-	golang.org/x/image v0.1.0
+	golang.org/x/image v0.18.0
+	golang.org/x/text v0.16.0
 )
 
 require golang.org/x/sys v0.0.0-20220722155257-8c9f86f7a55f // indirect
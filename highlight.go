@@ -1,7 +1,15 @@
 package main
 
 import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"strings"
+
 	"github.com/veandco/go-sdl2/sdl"
+	"golang.org/x/image/math/fixed"
 )
 
 type HiLineRects struct {
@@ -73,3 +81,112 @@ func (hlr *HiLineRects) UnShowRangeFrom(rng int) {
 func (hlr *HiLineRects) Len() int {
 	return len(hlr.rects)
 }
+
+var highlightColors = [numHighlightColors]color.RGBA{
+	HighlightYellow: {255, 230, 80, 255},
+	HighlightGreen:  {150, 230, 130, 255},
+	HighlightBlue:   {140, 190, 255, 255},
+	HighlightPink:   {255, 160, 200, 255},
+}
+
+var highlightColorNames = [numHighlightColors]string{
+	HighlightYellow: "yellow",
+	HighlightGreen:  "green",
+	HighlightBlue:   "blue",
+	HighlightPink:   "pink",
+}
+
+func (c HighlightColor) ToRGBA() color.RGBA {
+	if c < 0 || c >= numHighlightColors {
+		return highlightColors[HighlightYellow]
+	}
+	return highlightColors[c]
+}
+
+func (c HighlightColor) String() string {
+	if c < 0 || c >= numHighlightColors {
+		return highlightColorNames[HighlightYellow]
+	}
+	return highlightColorNames[c]
+}
+
+func (c HighlightColor) Next() HighlightColor {
+	return (c + 1) % numHighlightColors
+}
+
+// returns the index of the first highlight that contains offset, or -1
+func HighlightAt(hls []Highlight, offset int) int {
+	for i := range hls {
+		if offset >= hls[i].Start && offset < hls[i].End {
+			return i
+		}
+	}
+	return -1
+}
+
+// draws the part of every highlight that falls on line n, pt is the
+// baseline of that line (the same pt we pass to DrawGlyphs). Returns
+// everything it drew.
+func DrawHighlights(bg *image.RGBA, doc *Document, n int, pt fixed.Point26_6,
+	fam *FontFamily, fontSize float64, lineHeight int) []image.Rectangle {
+	var drawn []image.Rectangle
+	line := doc.Lines[n]
+	lineStart := doc.Offsets[n]
+	lineEnd := lineStart + len(line)
+
+	top := pt.Y.Round() - lineHeight*4/5
+	bottom := pt.Y.Round() + lineHeight/5
+
+	for _, hl := range doc.Highlights {
+		if hl.End <= lineStart || hl.Start >= lineEnd {
+			continue
+		}
+		from := 0
+		if hl.Start > lineStart {
+			from = hl.Start - lineStart
+		}
+		to := len(line)
+		if hl.End < lineEnd {
+			to = hl.End - lineStart
+		}
+
+		// more than one rect when the highlight crosses a direction change
+		for _, xs := range doc.RangeXs(n, from, to, fam, fontSize) {
+			rect := image.Rect(xs[0], top, xs[1], bottom)
+			draw.Draw(bg, rect, image.NewUniform(hl.Color.ToRGBA()), image.Point{0, 0}, draw.Src)
+			drawn = append(drawn, rect)
+		}
+
+		// a little mark in the margin where a note starts
+		if hl.Note != "" && hl.Start >= lineStart {
+			mark := image.Rect(2, top, 6, bottom)
+			draw.Draw(bg, mark, image.NewUniform(hl.Color.ToRGBA()), image.Point{0, 0}, draw.Src)
+			drawn = append(drawn, mark)
+		}
+	}
+	return drawn
+}
+
+func ExportHighlightsMarkdown(w io.Writer, doc *Document, hls []Highlight) error {
+	if _, err := fmt.Fprintf(w, "# Highlights: %s\n", doc.Name); err != nil {
+		return err
+	}
+	for _, hl := range hls {
+		if hl.Start < 0 || hl.End > len(doc.Text) || hl.Start >= hl.End {
+			continue
+		}
+		percent := hl.Start * 100 / len(doc.Text)
+		quoted := strings.ReplaceAll(strings.TrimSpace(doc.Text[hl.Start:hl.End]), "\n", "\n> ")
+
+		_, err := fmt.Fprintf(w, "\n## %d%% (%s)\n\n> %s\n", percent, hl.Color, quoted)
+		if err != nil {
+			return err
+		}
+		if hl.Note != "" {
+			if _, err := fmt.Fprintf(w, "\n%s\n", hl.Note); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHighlightAt(t *testing.T) {
+	hls := []Highlight{
+		{Start: 0, End: 5},
+		{Start: 10, End: 20},
+	}
+
+	type test struct {
+		in  int
+		out int
+	}
+
+	tests := []test{
+		{in: 0, out: 0},
+		{in: 4, out: 0},
+		{in: 5, out: -1},
+		{in: 15, out: 1},
+		{in: 20, out: -1},
+	}
+
+	for ntest, tt := range tests {
+		result := HighlightAt(hls, tt.in)
+		if result != tt.out {
+			const msg = "ntest: %d, got: %d, want %d\n"
+			t.Errorf(msg, ntest, result, tt.out)
+		}
+	}
+}
+
+func TestExportHighlightsMarkdown(t *testing.T) {
+	doc := NewDocument("test.txt", "The first line.\nThe second line.", 400, 10, nil)
+	hls := []Highlight{
+		{Start: 4, End: 9, Color: HighlightGreen},
+		{Start: 10, End: 27, Color: HighlightPink, Note: "two lines"},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportHighlightsMarkdown(&buf, doc, hls); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"# Highlights: test.txt",
+		"(green)\n\n> first\n",
+		"(pink)\n\n> line.\n> The second\n\ntwo lines\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(buf.String(), w) {
+			t.Errorf("%q not in:\n%s", w, buf.String())
+		}
+	}
+}
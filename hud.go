@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"runtime/metrics"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	hudFrames  = 120                    // frames in the graph
+	hudBudget  = time.Second / 60       // a frame over this misses vsync
+	hudRefresh = 250 * time.Millisecond // how often the numbers change, every frame is unreadable
+)
+
+// FramePerf is where the time of one frame went. Layout is the time
+// drawPage took minus the rasterizing it did, Upload is the page and the
+// atlas textures.
+type FramePerf struct {
+	Total, Layout, Raster, Upload time.Duration
+	Allocs                        uint64 // heap objects
+}
+
+// HeapAllocs is how many objects were allocated on the heap since the
+// program started, the difference between two calls is what was
+// allocated in between
+func HeapAllocs() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:objects"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// HUD is the performance overlay: a graph of the last frames, split into
+// what they spent their time on, and the numbers behind it
+type HUD struct {
+	*Overlay
+	frames []FramePerf // oldest first once it's full, see Add
+	next   int
+
+	updated time.Time
+}
+
+func NewHUD(renderer *sdl.Renderer, font *Font, rect sdl.Rect, fontSize float64) (*HUD, error) {
+	o, err := NewOverlay(renderer, font, rect, fontSize)
+	if err != nil {
+		return nil, err
+	}
+	return &HUD{Overlay: o}, nil
+}
+
+func (h *HUD) Add(p FramePerf) {
+	if len(h.frames) < hudFrames {
+		h.frames = append(h.frames, p)
+		return
+	}
+	h.frames[h.next] = p
+	h.next = (h.next + 1) % hudFrames
+}
+
+// Frames is the frames in the order they were drawn
+func (h *HUD) Frames() []FramePerf {
+	return append(append([]FramePerf(nil), h.frames[h.next:]...), h.frames[:h.next]...)
+}
+
+func percent(hits, misses int) float64 {
+	if hits+misses == 0 {
+		return 100
+	}
+	return 100 * float64(hits) / float64(hits+misses)
+}
+
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+// HUDLines is the text of the HUD, averages are over the frames in the
+// graph
+func HUDLines(frames []FramePerf, shapes *ShapeCache, atlas *GlyphAtlas) []string {
+	var sum FramePerf
+	var worst time.Duration
+	var maxAllocs uint64
+	for _, f := range frames {
+		sum.Total += f.Total
+		sum.Layout += f.Layout
+		sum.Raster += f.Raster
+		sum.Upload += f.Upload
+		sum.Allocs += f.Allocs
+		if f.Total > worst {
+			worst = f.Total
+		}
+		if f.Allocs > maxAllocs {
+			maxAllocs = f.Allocs
+		}
+	}
+	n := len(frames)
+	if n == 0 {
+		n = 1
+	}
+	avg := func(d time.Duration) string { return ms(d / time.Duration(n)) }
+
+	return []string{
+		fmt.Sprintf("frame %s  max %s", avg(sum.Total), ms(worst)),
+		fmt.Sprintf("layout %s  raster %s  upload %s", avg(sum.Layout), avg(sum.Raster), avg(sum.Upload)),
+		fmt.Sprintf("shape cache %.1f%%  %d strings", percent(shapes.Hits, shapes.Misses), shapes.Len()),
+		fmt.Sprintf("glyph atlas %.1f%%  %d glyphs  %d resets", percent(atlas.Hits, atlas.Misses), len(atlas.glyphs), atlas.resets),
+		fmt.Sprintf("allocs/frame %d  max %d", sum.Allocs/uint64(n), maxAllocs),
+	}
+}
+
+// Update redraws the numbers, not more than every hudRefresh
+func (h *HUD) Update(now time.Time, shapes *ShapeCache, atlas *GlyphAtlas) {
+	if now.Sub(h.updated) < hudRefresh {
+		return
+	}
+	h.updated = now
+	h.DrawLines(HUDLines(h.Frames(), shapes, atlas), -1)
+}
+
+// Present draws the text and the graph under it, every frame is a bar
+// that's layout (blue), raster (orange), upload (green) and the rest
+// (grey) stacked, the red line is hudBudget
+func (h *HUD) Present(renderer *sdl.Renderer) {
+	h.Overlay.Present(renderer)
+
+	frames := h.Frames()
+	graph := h.Rect
+	top := int32(overlayPadding + 5*h.LineHeight())
+	graph.Y += top
+	graph.H -= top + overlayPadding
+	graph.X += overlayPadding
+	graph.W -= 2 * overlayPadding
+	if graph.H <= 0 || len(frames) == 0 {
+		return
+	}
+
+	barW := graph.W / hudFrames
+	if barW < 1 {
+		barW = 1
+	}
+	scale := float64(graph.H) / float64(2*hudBudget) // the graph goes up to two frames
+	height := func(d time.Duration) int32 {
+		return int32(float64(d)*scale + 0.5)
+	}
+
+	var parts [4][]sdl.Rect
+	for i, f := range frames {
+		rest := f.Total - f.Layout - f.Raster - f.Upload
+		if rest < 0 {
+			rest = 0
+		}
+		y := graph.Y + graph.H
+		for k, d := range []time.Duration{f.Layout, f.Raster, f.Upload, rest} {
+			hh := height(d)
+			if y-hh < graph.Y {
+				hh = y - graph.Y
+			}
+			if hh <= 0 {
+				continue
+			}
+			y -= hh
+			parts[k] = append(parts[k], sdl.Rect{X: graph.X + int32(i)*barW, Y: y, W: barW, H: hh})
+		}
+	}
+	colors := []sdl.Color{{R: 40, G: 90, B: 220, A: 255}, {R: 240, G: 140, B: 0, A: 255}, {R: 30, G: 170, B: 60, A: 255}, {R: 140, G: 140, B: 140, A: 255}}
+	for k, rects := range parts {
+		if len(rects) == 0 {
+			continue
+		}
+		renderer.SetDrawColor(colors[k].R, colors[k].G, colors[k].B, colors[k].A)
+		renderer.FillRects(rects)
+	}
+
+	budget := graph.Y + graph.H - height(hudBudget)
+	renderer.SetDrawColor(220, 0, 0, 255)
+	renderer.DrawLine(graph.X, budget, graph.X+graph.W, budget)
+}
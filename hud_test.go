@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHUDFrames(t *testing.T) {
+	const msg = "ntest: %d, got: %d, want %d\n"
+	var h HUD
+	for i := 0; i < hudFrames+3; i++ {
+		h.Add(FramePerf{Allocs: uint64(i)})
+	}
+	frames := h.Frames()
+	if len(frames) != hudFrames {
+		t.Fatalf("got %d frames, want %d", len(frames), hudFrames)
+	}
+	// oldest first, the first 3 fell out
+	for i, f := range frames {
+		if f.Allocs != uint64(i+3) {
+			t.Errorf(msg, i, f.Allocs, i+3)
+		}
+	}
+}
+
+func TestHUDLines(t *testing.T) {
+	frames := []FramePerf{
+		{Total: 4 * time.Millisecond, Layout: 2 * time.Millisecond, Allocs: 10},
+		{Total: 2 * time.Millisecond, Upload: time.Millisecond, Allocs: 30},
+	}
+	shapes := NewShapeCache(100)
+	shapes.Hits, shapes.Misses = 3, 1
+	atlas := NewGlyphAtlas()
+
+	want := []string{
+		"frame 3.00ms  max 4.00ms",
+		"layout 1.00ms  raster 0.00ms  upload 0.50ms",
+		"shape cache 75.0%  0 strings",
+		"glyph atlas 100.0%  0 glyphs  0 resets",
+		"allocs/frame 20  max 30",
+	}
+	got := HUDLines(frames, shapes, atlas)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+var allocSink []byte
+
+func TestHeapAllocs(t *testing.T) {
+	before := HeapAllocs()
+	for i := 0; i < 1000; i++ {
+		allocSink = make([]byte, 1024)
+	}
+	// the runtime only counts allocations once their span is full, it
+	// can be a few behind
+	if after := HeapAllocs(); after < before+900 {
+		t.Errorf("got %d allocs, want about 1000", after-before)
+	}
+}
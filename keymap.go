@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// the names actions have in the keymap file and the help
+var actionNames = map[ActionKind]string{
+	ActionQuit:            "quit",
+	ActionBack:            "back",
+	ActionHelp:            "help",
+	ActionLineUp:          "line-up",
+	ActionLineDown:        "line-down",
+	ActionPageUp:          "page-up",
+	ActionPageDown:        "page-down",
+	ActionZoomIn:          "zoom-in",
+	ActionZoomOut:         "zoom-out",
+	ActionSearch:          "search",
+	ActionHighlight:       "highlight",
+	ActionHighlightColor:  "highlight-color",
+	ActionNote:            "note",
+	ActionDeleteHighlight: "delete-highlight",
+	ActionExport:          "export",
+	ActionConcordance:     "concordance",
+	ActionBookmark:        "bookmark",
+	ActionToggleBookmarks: "bookmarks",
+	ActionToggleHUD:       "hud",
+	ActionHalfPageUp:      "half-page-up",
+	ActionHalfPageDown:    "half-page-down",
+	ActionTop:             "top",
+	ActionBottom:          "bottom",
+	ActionVim:             "vim",
+	ActionWordNext:        "word-next",
+	ActionWordPrev:        "word-prev",
+	ActionLookup:          "lookup",
+	ActionIgnoreWord:      "ignore-word",
+}
+
+func (k ActionKind) String() string {
+	if name, ok := actionNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("action(%d)", int(k))
+}
+
+func ParseAction(name string) (ActionKind, bool) {
+	for kind, n := range actionNames {
+		if n == name {
+			return kind, true
+		}
+	}
+	return ActionNone, false
+}
+
+// only these modifiers count, caps lock or num lock don't change what
+// a key does
+const (
+	ModCtrl uint8 = 1 << iota
+	ModAlt
+	ModShift
+)
+
+// KeyCombo is a key with the modifiers held down with it, left and right
+// ctrl are the same thing
+type KeyCombo struct {
+	Sym sdl.Keycode
+	Mod uint8
+}
+
+func NewKeyCombo(sym sdl.Keycode, mod uint16) KeyCombo {
+	combo := KeyCombo{Sym: sym}
+	if mod&sdl.KMOD_CTRL != 0 {
+		combo.Mod |= ModCtrl
+	}
+	if mod&sdl.KMOD_ALT != 0 {
+		combo.Mod |= ModAlt
+	}
+	if mod&sdl.KMOD_SHIFT != 0 {
+		combo.Mod |= ModShift
+	}
+	return combo
+}
+
+// keys that aren't the character they type, everything else is
+var keyNames = map[string]sdl.Keycode{
+	"up": sdl.K_UP, "down": sdl.K_DOWN, "left": sdl.K_LEFT, "right": sdl.K_RIGHT,
+	"pageup": sdl.K_PAGEUP, "pagedown": sdl.K_PAGEDOWN, "home": sdl.K_HOME, "end": sdl.K_END,
+	"tab": sdl.K_TAB, "space": sdl.K_SPACE, "return": sdl.K_RETURN, "escape": sdl.K_ESCAPE,
+	"backspace": sdl.K_BACKSPACE, "delete": sdl.K_DELETE, "insert": sdl.K_INSERT,
+	"f1": sdl.K_F1, "f2": sdl.K_F2, "f3": sdl.K_F3, "f4": sdl.K_F4, "f5": sdl.K_F5, "f6": sdl.K_F6,
+	"f7": sdl.K_F7, "f8": sdl.K_F8, "f9": sdl.K_F9, "f10": sdl.K_F10, "f11": sdl.K_F11, "f12": sdl.K_F12,
+}
+
+// a few more ways to write the same key
+var keyAliases = map[string]string{
+	"enter": "return", "esc": "escape", "del": "delete", "pgup": "pageup", "pgdown": "pagedown",
+}
+
+var modNames = []struct {
+	name string
+	mod  uint8
+}{
+	{"ctrl", ModCtrl}, {"alt", ModAlt}, {"shift", ModShift},
+}
+
+// SDL sends the key and not the character, "?" is shift and the / key.
+// These are where they are on a US keyboard.
+var shiftedKeys = map[rune]rune{
+	'~': '`', '!': '1', '@': '2', '#': '3', '$': '4', '%': '5', '^': '6', '&': '7',
+	'*': '8', '(': '9', ')': '0', '_': '-', '+': '=', '{': '[', '}': ']', '|': '\\',
+	':': ';', '"': '\'', '<': ',', '>': '.', '?': '/',
+}
+
+// ParseKeyCombo reads keys the way String writes them: "f", "ctrl+f",
+// "shift+pagedown". The modifiers and the names of keys can be written in
+// any case, a character is the one it types: "G" is shift+g and "?" is
+// shift+/.
+func ParseKeyCombo(s string) (KeyCombo, error) {
+	var combo KeyCombo
+	parts := strings.Split(strings.TrimSpace(s), "+")
+	// "ctrl++" is ctrl and the plus key
+	if len(parts) > 1 && parts[len(parts)-1] == "" && parts[len(parts)-2] == "" {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+
+	for _, part := range parts[:len(parts)-1] {
+		found := false
+		for _, m := range modNames {
+			if strings.ToLower(part) == m.name {
+				combo.Mod |= m.mod
+				found = true
+			}
+		}
+		if !found {
+			return combo, fmt.Errorf("key %q: unknown modifier %q", s, part)
+		}
+	}
+
+	key := parts[len(parts)-1]
+	// printable keys are the character they type
+	if r, size := utf8.DecodeRuneInString(key); size == len(key) && r > ' ' && r < utf8.RuneSelf {
+		if base, ok := shiftedKeys[r]; ok {
+			r = base
+			combo.Mod |= ModShift
+		} else if r >= 'A' && r <= 'Z' {
+			r += 'a' - 'A'
+			combo.Mod |= ModShift
+		}
+		combo.Sym = sdl.Keycode(r)
+		return combo, nil
+	}
+	key = strings.ToLower(key)
+	if alias, ok := keyAliases[key]; ok {
+		key = alias
+	}
+	if sym, ok := keyNames[key]; ok {
+		combo.Sym = sym
+		return combo, nil
+	}
+	return combo, fmt.Errorf("key %q: unknown key %q", s, key)
+}
+
+func (c KeyCombo) String() string {
+	mod, key := c.Mod, ""
+	// shift and a character is the character shift types
+	if mod&ModShift != 0 {
+		if c.Sym >= sdl.K_a && c.Sym <= sdl.K_z {
+			key = string(rune(c.Sym) - 'a' + 'A')
+		}
+		for shifted, base := range shiftedKeys {
+			if sdl.Keycode(base) == c.Sym {
+				key = string(shifted)
+			}
+		}
+		if key != "" {
+			mod &^= ModShift
+		}
+	}
+
+	var b strings.Builder
+	for _, m := range modNames {
+		if mod&m.mod != 0 {
+			b.WriteString(m.name + "+")
+		}
+	}
+	if key != "" {
+		b.WriteString(key)
+		return b.String()
+	}
+	for name, sym := range keyNames {
+		if sym == c.Sym {
+			b.WriteString(name)
+			return b.String()
+		}
+	}
+	b.WriteRune(rune(c.Sym))
+	return b.String()
+}
+
+// Keymap is what the keys do on the page, the prompt and the bookmark
+// panel have keys of their own (escape closes the prompt)
+type Keymap map[KeyCombo]ActionKind
+
+// the keys we always had, F1 for the help and v for vim mode
+var defaultKeys = map[ActionKind][]string{
+	ActionBack:            {"escape"},
+	ActionHelp:            {"f1"},
+	ActionLineUp:          {"up"},
+	ActionLineDown:        {"down"},
+	ActionPageUp:          {"left"},
+	ActionPageDown:        {"right"},
+	ActionZoomIn:          {"f"},
+	ActionZoomOut:         {"b"},
+	ActionSearch:          {"ctrl+f"},
+	ActionHighlight:       {"h"},
+	ActionHighlightColor:  {"c"},
+	ActionNote:            {"n"},
+	ActionDeleteHighlight: {"x"},
+	ActionExport:          {"e"},
+	ActionConcordance:     {"o"},
+	ActionBookmark:        {"m"},
+	ActionToggleBookmarks: {"tab"},
+	ActionToggleHUD:       {"f3"},
+	ActionVim:             {"v"},
+}
+
+func DefaultKeymap() Keymap {
+	keys, err := NewKeymap(defaultKeys)
+	if err != nil {
+		panic(err) // the defaults are ours, this is a bug
+	}
+	return keys
+}
+
+// NewKeymap binds every key in keys to its action, it's an error when
+// one key ends up with two actions
+func NewKeymap(keys map[ActionKind][]string) (Keymap, error) {
+	km := make(Keymap)
+	var errs []string
+
+	// sorted so that the errors come out the same every time
+	kinds := make([]ActionKind, 0, len(keys))
+	for kind := range keys {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+
+	for _, kind := range kinds {
+		for _, s := range keys[kind] {
+			combo, err := ParseKeyCombo(s)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			if old, ok := km[combo]; ok && old != kind {
+				errs = append(errs, fmt.Sprintf("%s is bound to both %s and %s", combo, old, kind))
+				continue
+			}
+			km[combo] = kind
+		}
+	}
+	if len(errs) > 0 {
+		return km, fmt.Errorf("keymap: %s", strings.Join(errs, "; "))
+	}
+	return km, nil
+}
+
+// LoadKeymap reads a json file of action names to keys:
+//
+//	{"zoom-in": ["f", "ctrl+="], "search": ["ctrl+f", "/"], "hud": []}
+//
+// the actions in it get these keys instead of their default ones, an
+// empty list unbinds one. No file means the defaults.
+func LoadKeymap(path string) (Keymap, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return DefaultKeymap(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var config map[string][]string
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	keys := make(map[ActionKind][]string)
+	for kind, k := range defaultKeys {
+		keys[kind] = k
+	}
+	for name, k := range config {
+		kind, ok := ParseAction(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown action %q", path, name)
+		}
+		keys[kind] = k
+	}
+	km, err := NewKeymap(keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return km, nil
+}
+
+func (km Keymap) Action(sym sdl.Keycode, mod uint16) ActionKind {
+	return km[NewKeyCombo(sym, mod)]
+}
+
+// Keys are the keys bound to kind, sorted
+func (km Keymap) Keys(kind ActionKind) []string {
+	var keys []string
+	for combo, k := range km {
+		if k == kind {
+			keys = append(keys, combo.String())
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Help is a line for every action that has keys, in the order of the
+// actions, for the help overlay
+func (km Keymap) Help() []string {
+	bound := make(map[ActionKind][]string)
+	for combo, kind := range km {
+		bound[kind] = append(bound[kind], combo.String())
+	}
+	kinds := make([]ActionKind, 0, len(bound))
+	for kind := range bound {
+		sort.Strings(bound[kind])
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+
+	lines := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		lines = append(lines, fmt.Sprintf("%-18s %s", kind, strings.Join(bound[kind], ", ")))
+	}
+	return lines
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func TestParseKeyCombo(t *testing.T) {
+	const msg = "ntest: %d, got: %v, want %v\n"
+	tests := []struct {
+		in   string
+		want KeyCombo
+		str  string
+	}{
+		{"f", KeyCombo{Sym: sdl.K_f}, "f"},
+		{"Ctrl+f", KeyCombo{Sym: sdl.K_f, Mod: ModCtrl}, "ctrl+f"},
+		{"ctrl+F", KeyCombo{Sym: sdl.K_f, Mod: ModCtrl | ModShift}, "ctrl+F"},
+		{"G", KeyCombo{Sym: sdl.K_g, Mod: ModShift}, "G"},
+		{"shift+g", KeyCombo{Sym: sdl.K_g, Mod: ModShift}, "G"},
+		{"?", KeyCombo{Sym: sdl.K_SLASH, Mod: ModShift}, "?"},
+		{":", KeyCombo{Sym: sdl.K_SEMICOLON, Mod: ModShift}, ":"},
+		{"shift+1", KeyCombo{Sym: sdl.K_1, Mod: ModShift}, "!"},
+		{"shift+ctrl+pagedown", KeyCombo{Sym: sdl.K_PAGEDOWN, Mod: ModCtrl | ModShift}, "ctrl+shift+pagedown"},
+		{"alt+enter", KeyCombo{Sym: sdl.K_RETURN, Mod: ModAlt}, "alt+return"},
+		{"ctrl++", KeyCombo{Sym: sdl.K_EQUALS, Mod: ModCtrl | ModShift}, "ctrl++"},
+		{"/", KeyCombo{Sym: sdl.K_SLASH}, "/"},
+		{"F3", KeyCombo{Sym: sdl.K_F3}, "f3"},
+		{"Esc", KeyCombo{Sym: sdl.K_ESCAPE}, "escape"},
+	}
+	for i, test := range tests {
+		got, err := ParseKeyCombo(test.in)
+		if err != nil || got != test.want {
+			t.Errorf(msg, i, got, test.want)
+		}
+		if got.String() != test.str {
+			t.Errorf(msg, i, got.String(), test.str)
+		}
+	}
+
+	for _, bad := range []string{"", "hyper+f", "ctrl+", "pageupp", "ä"} {
+		if _, err := ParseKeyCombo(bad); err == nil {
+			t.Errorf("%q parsed\n", bad)
+		}
+	}
+}
+
+func TestKeymapConflicts(t *testing.T) {
+	if _, err := NewKeymap(defaultKeys); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewKeymap(map[ActionKind][]string{
+		ActionZoomIn: {"F"},
+		ActionSearch: {"shift+f", "/"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "F is bound to both zoom-in and search") {
+		t.Errorf("got: %v, want a conflict\n", err)
+	}
+}
+
+func TestLoadKeymap(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, config string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(config), FILE_MODE_RW); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	const msg = "ntest: %d, got: %s, want %s\n"
+	km, err := LoadKeymap(write("keys.json", `{"search": ["/"], "zoom-in": ["ctrl+="], "line-down": ["down", "j"], "top": ["G"], "hud": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sym  sdl.Keycode
+		mod  uint16
+		want ActionKind
+	}{
+		{sdl.Keycode('/'), sdl.KMOD_NONE, ActionSearch},
+		{sdl.K_f, sdl.KMOD_LCTRL, ActionNone},
+		{sdl.K_EQUALS, sdl.KMOD_RCTRL, ActionZoomIn},
+		{sdl.K_f, sdl.KMOD_NONE, ActionNone},
+		{sdl.K_j, sdl.KMOD_NONE, ActionLineDown},
+		{sdl.K_DOWN, sdl.KMOD_NONE, ActionLineDown},
+		{sdl.K_F3, sdl.KMOD_NONE, ActionNone},
+		{sdl.K_g, sdl.KMOD_RSHIFT, ActionTop},
+		{sdl.K_g, sdl.KMOD_NONE, ActionNone},
+		// the ones the file doesn't mention keep their keys
+		{sdl.K_b, sdl.KMOD_NONE, ActionZoomOut},
+		{sdl.K_RIGHT, sdl.KMOD_NONE, ActionPageDown},
+	}
+	for i, test := range tests {
+		if got := km.Action(test.sym, test.mod); got != test.want {
+			t.Errorf(msg, i, got, test.want)
+		}
+	}
+
+	if km, err := LoadKeymap(filepath.Join(dir, "nothing.json")); err != nil || len(km) != len(DefaultKeymap()) {
+		t.Errorf("no file: got %d keys (%v), want the defaults\n", len(km), err)
+	}
+	for _, bad := range []string{`{"fly": ["f"]}`, `{"search": ["b"]}`, `{"search": "f"}`, `{"search": ["ctrl+"]}`} {
+		if _, err := LoadKeymap(write("bad.json", bad)); err == nil {
+			t.Errorf("%s loaded\n", bad)
+		}
+	}
+}
+
+func TestKeymapHelp(t *testing.T) {
+	km, err := NewKeymap(map[ActionKind][]string{
+		ActionZoomOut: {"b", "-"},
+		ActionPageUp:  {"left"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"page-up            left",
+		"zoom-out           -, b",
+	}
+	got := km.Help()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s\n", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestAppKeymap(t *testing.T) {
+	app, clock := testApp(t)
+	km, err := NewKeymap(map[ActionKind][]string{ActionLineDown: {"j"}, ActionHelp: {"?"}, ActionBack: {"q"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.keys = km
+
+	app.HandleEvent(keyUp(sdl.K_j, sdl.KMOD_NONE))
+	app.HandleEvent(keyUp(sdl.K_DOWN, sdl.KMOD_NONE))
+	settleApp(t, app, clock)
+	if app.startIndex != 1 {
+		t.Errorf("got: %d, want 1\n", app.startIndex)
+	}
+
+	// this is what SDL sends for ?, the key is /
+	app.HandleEvent(keyUp(sdl.K_SLASH, sdl.KMOD_NONE))
+	if app.showHelp {
+		t.Errorf("/ showed the help\n")
+	}
+	app.HandleEvent(keyUp(sdl.K_SLASH, sdl.KMOD_LSHIFT))
+	if !app.showHelp {
+		t.Errorf("? didn't show the help\n")
+	}
+
+	// escape isn't back anymore, q is
+	app.HandleEvent(keyUp(sdl.K_ESCAPE, sdl.KMOD_NONE))
+	if !app.showHelp || !app.running {
+		t.Errorf("escape did something\n")
+	}
+	app.HandleEvent(keyUp(sdl.K_q, sdl.KMOD_NONE))
+	if app.showHelp || !app.running {
+		t.Errorf("q didn't close the help\n")
+	}
+	app.HandleEvent(keyUp(sdl.K_q, sdl.KMOD_NONE))
+	if app.running {
+		t.Errorf("q didn't quit\n")
+	}
+}
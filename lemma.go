@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Lemmatizer maps a surface form ("runs", "ran") to the key we store the
+// word under in the database ("run"). Implementations have to be safe to
+// call with any trimmed word, including ones in a different language.
+type Lemmatizer interface {
+	Lemma(word string) string
+}
+
+// IdentityLemmatizer keeps every form as its own entry
+type IdentityLemmatizer struct{}
+
+func (IdentityLemmatizer) Lemma(word string) string {
+	return word
+}
+
+// LookupLemmatizer uses a table of form -> lemma and falls back to
+// another lemmatizer (usually a stemmer) for forms that aren't in it.
+type LookupLemmatizer struct {
+	Table    map[string]string
+	Fallback Lemmatizer
+}
+
+func (l *LookupLemmatizer) Lemma(word string) string {
+	if lemma, ok := l.Table[strings.ToLower(word)]; ok {
+		return lemma
+	}
+	if l.Fallback == nil {
+		return word
+	}
+	return l.Fallback.Lemma(word)
+}
+
+// LoadLemmaTable reads a file with a "form lemma" pair on every line
+// (tab or space separated), lines starting with # are comments.
+func LoadLemmaTable(path string, fallback Lemmatizer) (*LookupLemmatizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	table := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		table[strings.ToLower(fields[0])] = strings.ToLower(fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &LookupLemmatizer{Table: table, Fallback: fallback}, nil
+}
+
+var stemmers = map[string]Lemmatizer{
+	"en": EnglishStemmer{},
+	"ru": RussianStemmer{},
+	"de": GermanStemmer{},
+}
+
+// NewLemmatizer picks the built-in stemmer for lang and puts
+// <dir><lang>.txt in front of it if there is one.
+func NewLemmatizer(lang string, dir string) (Lemmatizer, error) {
+	var lem Lemmatizer = IdentityLemmatizer{}
+	if stemmer, ok := stemmers[lang]; ok {
+		lem = stemmer
+	}
+	if lang == "" {
+		return lem, nil
+	}
+
+	table, err := LoadLemmaTable(dir+lang+".txt", lem)
+	if os.IsNotExist(err) {
+		return lem, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// words that are in almost every text of a language and not in the
+// others, for DetectLanguage
+var commonWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "was", "that", "it", "he", "she", "you", "with"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "sie", "zu", "ein", "mit", "den"},
+}
+
+// DetectLanguage guesses the language of text for the stemmer: Cyrillic is
+// Russian, English and German are told apart by their common words. It's
+// "" when it can't tell, that's the identity lemmatizer, so a text we
+// don't know doesn't get stemmed like it was English.
+func DetectLanguage(text string) string {
+	const sample = 5000 // words, the start of a text is enough
+	var cyrillic, latin int
+	hits := make(map[string]int)
+	common := make(map[string]string)
+	for lang, words := range commonWords {
+		for _, w := range words {
+			common[w] = lang
+		}
+	}
+
+	for i, w := range strings.Fields(text) {
+		if i >= sample {
+			break
+		}
+		for _, r := range w {
+			switch {
+			case unicode.Is(unicode.Cyrillic, r):
+				cyrillic++
+			case unicode.Is(unicode.Latin, r):
+				latin++
+			}
+		}
+		if lang, ok := common[strings.ToLower(strings.Trim(w, wordPunctuation))]; ok {
+			hits[lang]++
+		}
+	}
+
+	switch {
+	case cyrillic > latin:
+		return "ru"
+	case hits["en"] >= 3 && hits["en"] > 2*hits["de"]:
+		return "en"
+	case hits["de"] >= 3 && hits["de"] > 2*hits["en"]:
+		return "de"
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnglishStemmer(t *testing.T) {
+	// taken from the examples in Porter's paper
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"conflated":      "conflat",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"generalization": "gener",
+		"electricity":    "electr",
+		"Running":        "run",
+		"runs":           "run",
+		"is":             "is",
+		"l'amour":        "l'amour",
+	}
+
+	for in, out := range tests {
+		if got := (EnglishStemmer{}).Lemma(in); got != out {
+			t.Errorf("%s: got %s, want %s", in, got, out)
+		}
+	}
+}
+
+func TestRussianStemmer(t *testing.T) {
+	tests := map[string]string{
+		"книга":      "книг",
+		"книги":      "книг",
+		"книгу":      "книг",
+		"книгой":     "книг",
+		"красивый":   "красив",
+		"красивая":   "красив",
+		"красивыми":  "красив",
+		"важная":     "важн",
+		"важнейшими": "важн",
+		"читать":     "чита",
+		"читала":     "чита",
+		"ёлка":       "елк",
+	}
+
+	for in, out := range tests {
+		if got := (RussianStemmer{}).Lemma(in); got != out {
+			t.Errorf("%s: got %s, want %s", in, got, out)
+		}
+	}
+}
+
+func TestGermanStemmer(t *testing.T) {
+	tests := map[string]string{
+		"häuser":             "haus",
+		"Haus":               "haus",
+		"katzen":             "katz",
+		"laufen":             "lauf",
+		"aufeinanderfolgend": "aufeinanderfolg",
+		"straße":             "strass",
+	}
+
+	for in, out := range tests {
+		if got := (GermanStemmer{}).Lemma(in); got != out {
+			t.Errorf("%s: got %s, want %s", in, got, out)
+		}
+	}
+}
+
+func TestLookupLemmatizer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "en.txt")
+	table := "# irregular verbs\nran\trun\nwent go\n\nbroken\n"
+	if err := ioutil.WriteFile(path, []byte(table), FILE_MODE_RW); err != nil {
+		t.Fatal(err)
+	}
+
+	lem, err := LoadLemmaTable(path, EnglishStemmer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"ran":     "run",
+		"Went":    "go",
+		"running": "run", // not in the table, the stemmer gets it
+		"broken":  "broken",
+	}
+	for in, out := range tests {
+		if got := lem.Lemma(in); got != out {
+			t.Errorf("%s: got %s, want %s", in, got, out)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	const msg = "ntest: %d, got: %q, want %q\n"
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"It was a bright cold day in April, and the clocks were striking thirteen. He said that it was late.", "en"},
+		{"Es war ein heller, kalter Tag im April, und die Uhren schlugen dreizehn. Ich weiß es nicht, sagte sie.", "de"},
+		{"Был холодный ясный апрельский день, и часы пробили тринадцать. The end.", "ru"},
+		{"C'était une journée d'avril froide et claire, les horloges sonnaient treize heures.", ""},
+		{"", ""},
+	}
+	for i, test := range tests {
+		if got := DetectLanguage(test.text); got != test.want {
+			t.Errorf(msg, i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A (much) simplified version of the Unicode line breaking algorithm
+// (UAX #14). We don't have the full LineBreak.txt table, the classes below
+// cover the characters that actually show up in the texts we read and
+// everything else is treated as AL, which is what the spec tells you to
+// do with unknown characters anyway.
+type lbClass int
+
+const (
+	lbAL lbClass = iota // letters and everything we don't know about
+	lbSP                // space
+	lbGL                // non-breaking glue
+	lbZW                // zero width space
+	lbWJ                // word joiner
+	lbCL                // closing punctuation
+	lbCP                // closing parenthesis
+	lbOP                // opening punctuation
+	lbQU                // quotes
+	lbNS                // non-starters, small kana and friends
+	lbEX                // ! and ?
+	lbIS                // infix separators, , . : ;
+	lbSY                // /
+	lbNU                // digits
+	lbPR                // prefix, $ and friends
+	lbPO                // postfix, % and friends
+	lbHY                // hyphen-minus
+	lbBA                // break after
+	lbBB                // break before
+	lbB2                // em dash
+	lbIN                // ellipsis
+	lbID                // ideographs, a line can break between any two
+	lbSA                // thai, lao, khmer... needs a dictionary
+	lbCM                // combining marks
+)
+
+func lineBreakClass(r rune) lbClass {
+	switch r {
+	case ' ':
+		return lbSP
+	case '\t', '\u00ad', '\u2010', '\u2012', '\u2013', '|', '\u3000':
+		return lbBA
+	case '\u00a0', '\u202f', '\u2007':
+		return lbGL
+	case '\u200b':
+		return lbZW
+	case '\u2060', '\ufeff':
+		return lbWJ
+	case ')', ']':
+		return lbCP
+	case '}', '、', '。', '，', '．', '」', '』', '】', '》', '〉', '〕', '〗', '〙', '〛', '）', '］', '｝':
+		return lbCL
+	case '(', '[', '{', '¡', '¿', '「', '『', '【', '《', '〈', '〔', '〖', '〘', '〚', '（', '［', '｛':
+		return lbOP
+	case '"', '\'', '«', '»', '‘', '’', '“', '”', '‹', '›':
+		return lbQU
+	case '!', '?', '！', '？':
+		return lbEX
+	case ',', '.', ':', ';':
+		return lbIS
+	case '/':
+		return lbSY
+	case '-':
+		return lbHY
+	case '—':
+		return lbB2
+	case '´', 'ˈ', 'ˌ':
+		return lbBB
+	case '․', '‥', '…':
+		return lbIN
+	case '$', '£', '¥', '€', '+', '\\', '#', '№':
+		return lbPR
+	case '%', '¢', '°', '‰', '′', '″':
+		return lbPO
+	case 'ー', '々', '〻', 'ゝ', 'ゞ', 'ヽ', 'ヾ', '・', '：', '；', '‼', '⁇', '⁈', '⁉',
+		'ぁ', 'ぃ', 'ぅ', 'ぇ', 'ぉ', 'っ', 'ゃ', 'ゅ', 'ょ', 'ゎ',
+		'ァ', 'ィ', 'ゥ', 'ェ', 'ォ', 'ッ', 'ャ', 'ュ', 'ョ', 'ヮ', 'ヵ', 'ヶ':
+		return lbNS
+	}
+
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me):
+		return lbCM
+	case unicode.Is(unicode.Nd, r):
+		return lbNU
+	case unicode.In(r, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar):
+		return lbSA
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul),
+		r >= 0x3000 && r <= 0x303f, r >= 0xff00 && r <= 0xffef:
+		return lbID
+	}
+	return lbAL
+}
+
+// can we break between a and b when there's no space between them
+func pairBreaks(a, b lbClass) bool {
+	switch {
+	case a == lbZW: // LB8
+		return true
+	case a == lbWJ || b == lbWJ: // LB11
+		return false
+	case a == lbGL: // LB12
+		return false
+	case b == lbGL && a != lbBA && a != lbHY: // LB12a
+		return false
+	case b == lbCL || b == lbCP || b == lbEX || b == lbIS || b == lbSY: // LB13
+		return false
+	case a == lbOP: // LB14
+		return false
+	case a == lbB2 && b == lbB2: // LB17
+		return false
+	case a == lbQU || b == lbQU: // LB19
+		return false
+	case b == lbBA || b == lbHY || b == lbNS || a == lbBB: // LB21
+		return false
+	case b == lbIN: // LB22
+		return false
+	case (a == lbAL && b == lbNU) || (a == lbNU && b == lbAL): // LB23
+		return false
+	case a == lbID && b == lbPO, a == lbPR && b == lbID: // LB23a
+		return false
+	case (a == lbPR || a == lbPO) && (b == lbAL || b == lbNU || b == lbOP): // LB24, LB25
+		return false
+	case (a == lbAL || a == lbNU) && (b == lbPR || b == lbPO): // LB24, LB25
+		return false
+	case b == lbNU && (a == lbNU || a == lbHY || a == lbIS || a == lbSY): // LB25
+		return false
+	case a == lbAL && b == lbAL: // LB28
+		return false
+	case a == lbIS && b == lbAL: // LB29
+		return false
+	case (a == lbAL || a == lbNU) && b == lbOP, a == lbCP && (b == lbAL || b == lbNU): // LB30
+		return false
+	}
+	return true // LB31
+}
+
+// can we break before b when there are spaces between it and a
+func spaceBreaks(a, b lbClass) bool {
+	switch {
+	case b == lbCL || b == lbCP || b == lbEX || b == lbIS || b == lbSY: // LB13
+		return false
+	case a == lbOP: // LB14
+		return false
+	case a == lbQU && b == lbOP: // LB15
+		return false
+	case (a == lbCL || a == lbCP) && b == lbNS: // LB16
+		return false
+	case a == lbB2 && b == lbB2: // LB17
+		return false
+	}
+	return true // LB18
+}
+
+// word boundaries inside runs of SA characters, which is the only
+// place where line breaking needs a dictionary
+func saBreaks(s string, seg Segmenter) map[int]bool {
+	result := make(map[int]bool)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if lineBreakClass(r) != lbSA {
+			i += size
+			continue
+		}
+		start := i
+		for i < len(s) {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if c := lineBreakClass(r); c != lbSA && c != lbCM {
+				break
+			}
+			i += size
+		}
+		for _, span := range seg.Segment(s[start:i]) {
+			if span.Start > 0 {
+				result[start+span.Start] = true
+			}
+		}
+	}
+	return result
+}
+
+// LineBreaks returns the byte offsets where s may be broken, a line
+// that ends at one of them ends with its trailing spaces. 0 and len(s)
+// are never in the result.
+func LineBreaks(s string, seg Segmenter) []int {
+	if seg == nil {
+		seg = SpaceSegmenter{}
+	}
+	sa := saBreaks(s, seg)
+
+	var (
+		result       []int
+		prev         lbClass
+		prevNonSpace lbClass
+		spaces       bool
+	)
+	for i, r := range s {
+		class := lineBreakClass(r)
+		if i == 0 {
+			if class == lbCM {
+				class = lbAL // LB10
+			}
+			prev, prevNonSpace, spaces = class, class, class == lbSP
+			continue
+		}
+
+		if class == lbCM {
+			if prev != lbSP && prev != lbZW {
+				continue // LB9, marks stick to what's before them
+			}
+			class = lbAL // LB10
+		}
+
+		if class == lbSP {
+			// LB7, never break before a space
+			prev, spaces = lbSP, true
+			continue
+		}
+
+		var brk bool
+		switch {
+		case class == lbZW: // LB7
+			brk = false
+		case spaces && prevNonSpace == lbSP:
+			brk = false // don't break off the indentation
+		case spaces:
+			brk = spaceBreaks(prevNonSpace, class)
+		case prev == lbSA && class == lbSA:
+			brk = sa[i]
+		default:
+			brk = pairBreaks(saAsAL(prev), saAsAL(class))
+		}
+		if brk {
+			result = append(result, i)
+		}
+		prev, prevNonSpace, spaces = class, class, false
+	}
+	return result
+}
+
+// LB1, outside of an SA run SA characters behave like letters
+func saAsAL(c lbClass) lbClass {
+	if c == lbSA {
+		return lbAL
+	}
+	return c
+}
+
+// LastBreak returns the last break in breaks that is > start and <= end,
+// or -1 if there isn't one
+func LastBreak(breaks []int, start, end int) int {
+	i := sort.SearchInts(breaks, end+1) - 1
+	if i < 0 || breaks[i] <= start {
+		return -1
+	}
+	return breaks[i]
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	updateStep   = time.Second / 60 // scrolling is simulated in steps this long, however fast we draw
+	maxUpdates   = 5                // after a frame that long we'd rather skip ahead than catch up
+	idleWait     = 1000             // ms we sleep waiting for an event when nothing moves
+	frameHistory = 240              // frames FrameStats keeps
+)
+
+// FixedStep turns the time between frames into a number of fixed size
+// updates, so that a fling scrolls as far on a 144Hz screen as on a 60Hz
+// one, and as far when a frame was late.
+type FixedStep struct {
+	Step time.Duration
+
+	acc  time.Duration
+	last time.Time
+}
+
+// Steps is how many updates the frame at now has to run, the first
+// frame after Reset runs one so that things start moving right away
+func (f *FixedStep) Steps(now time.Time) int {
+	if f.last.IsZero() {
+		f.last = now
+		return 1
+	}
+	f.acc += now.Sub(f.last)
+	f.last = now
+	n := int(f.acc / f.Step)
+	if n > maxUpdates {
+		f.acc = 0
+		return maxUpdates
+	}
+	f.acc -= time.Duration(n) * f.Step
+	return n
+}
+
+// Reset forgets about the time since the last frame, the loop calls it
+// before it goes to sleep, time spent waiting for events isn't something
+// to catch up on
+func (f *FixedStep) Reset() {
+	f.acc = 0
+	f.last = time.Time{}
+}
+
+// FrameStats keeps how long the last frames took to draw, not counting
+// the time Present waits for vsync or the loop waits for events
+type FrameStats struct {
+	times  []time.Duration
+	next   int
+	Frames int
+}
+
+func (s *FrameStats) Add(d time.Duration) {
+	s.Frames++
+	if len(s.times) < frameHistory {
+		s.times = append(s.times, d)
+		return
+	}
+	s.times[s.next] = d
+	s.next = (s.next + 1) % frameHistory
+}
+
+// Summary is over the last frameHistory frames
+func (s *FrameStats) Summary() (avg, p95, max time.Duration) {
+	if len(s.times) == 0 {
+		return 0, 0, 0
+	}
+	sorted := append([]time.Duration(nil), s.times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	avg = total / time.Duration(len(sorted))
+	p95 = sorted[(len(sorted)-1)*95/100]
+	max = sorted[len(sorted)-1]
+	return avg, p95, max
+}
+
+func (s *FrameStats) String() string {
+	avg, p95, max := s.Summary()
+	return fmt.Sprintf("%d frames, avg %v, p95 %v, max %v", s.Frames,
+		avg.Round(time.Microsecond), p95.Round(time.Microsecond), max.Round(time.Microsecond))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFixedStep(t *testing.T) {
+	const msg = "ntest: %d, got: %d, want %d\n"
+	clock := NewFakeClock()
+	f := FixedStep{Step: 10 * time.Millisecond}
+
+	tests := []struct {
+		frame time.Duration
+		want  int
+	}{
+		{0, 1}, // the first frame after Reset
+		{10 * time.Millisecond, 1},
+		{4 * time.Millisecond, 0},
+		{4 * time.Millisecond, 0},
+		{4 * time.Millisecond, 1}, // 12ms, the 2ms left over carry on
+		{28 * time.Millisecond, 3},
+		{time.Second, maxUpdates},
+		{10 * time.Millisecond, 1}, // the long frame didn't leave a backlog
+	}
+	for i, tt := range tests {
+		clock.Advance(tt.frame)
+		if got := f.Steps(clock.Now()); got != tt.want {
+			t.Errorf(msg, i, got, tt.want)
+		}
+	}
+
+	// sleeping doesn't count
+	f.Reset()
+	clock.Advance(time.Minute)
+	if got := f.Steps(clock.Now()); got != 1 {
+		t.Errorf("after Reset got %d, want 1", got)
+	}
+}
+
+func TestFrameStats(t *testing.T) {
+	var s FrameStats
+	if avg, p95, max := s.Summary(); avg != 0 || p95 != 0 || max != 0 {
+		t.Errorf("no frames got %v %v %v, want 0s", avg, p95, max)
+	}
+
+	for i := 1; i <= 100; i++ {
+		s.Add(time.Duration(i) * time.Millisecond)
+	}
+	avg, p95, max := s.Summary()
+	if avg != 50500*time.Microsecond || p95 != 95*time.Millisecond || max != 100*time.Millisecond {
+		t.Errorf("got avg %v p95 %v max %v, want 50.5ms 95ms 100ms", avg, p95, max)
+	}
+
+	// only the last frameHistory frames count
+	for i := 0; i < frameHistory; i++ {
+		s.Add(time.Millisecond)
+	}
+	if _, _, max := s.Summary(); max != time.Millisecond {
+		t.Errorf("old frames still counted, max %v", max)
+	}
+	if s.Frames != 100+frameHistory {
+		t.Errorf("got %d frames, want %d", s.Frames, 100+frameHistory)
+	}
+}
@@ -3,18 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
-	"image"
-	"image/color"
-	"image/draw"
-	"io/ioutil"
 	"log"
 	"os"
 	"runtime"
 	"runtime/pprof"
 	"time"
-	"unsafe"
 
-	"github.com/golang/freetype"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
@@ -24,27 +18,37 @@ var (
 
 	fontStr = flag.String("font", "", "usage: -font=<fname>.<ftype>")
 	textStr = flag.String("text", "", "usage: -text=<fname>.<ftype>")
-)
+	monoStr = flag.String("monofont", "", "usage: -monofont=<fname>.<ftype>, the font for code in markdown texts")
+	langStr = flag.String("lang", "", "usage: -lang=<en|ru|de>, picks the stemmer and ./lemmas/<lang>.txt, guessed from the text without it")
 
-func MouseOverWords(event *sdl.MouseMotionEvent, ctx *freetype.Context, r *[]WordRects, mouseOver *[]bool) {
-	var fontSize = ctx.PointToFixed(18.0).Round()
-	for index := range *r {
-		mx_gt_rx := int(event.X) > (*r)[index].Rect.Min.X
-		mx_lt_rx_rw := int(event.X) < (*r)[index].Rect.Max.X
-		my_gt_ry := int(event.Y) > (*r)[index].Rect.Min.Y-fontSize
-		my_lt_ry_rh := int(event.Y) < (*r)[index].Rect.Max.Y
-
-		if (mx_gt_rx && mx_lt_rx_rw) && (my_gt_ry && my_lt_ry_rh) {
-			(*mouseOver)[index] = true
-		} else {
-			(*mouseOver)[index] = false
-		}
-	}
-}
+	listRecent = flag.Bool("recent", false, "list recently opened texts and exit")
+	showDamage = flag.Bool("showdamage", false, "show the parts of the page that get uploaded every frame")
+	frameTimes = flag.Bool("frametimes", false, "print how long frames take to draw, once a second while something is drawn")
+	hudFlag    = flag.Bool("hud", false, "start with the performance HUD on, F3 toggles it")
+	vimFlag    = flag.Bool("vim", false, "start in vim mode, v or escape leave it")
+	keysStr    = flag.String("keys", "keys.json", "usage: -keys=<fname>.json, what the keys do, F1 shows them")
+
+	renderTo   = flag.String("render-to", "", "usage: -render-to=page.png, draw the page into a png without opening a window and exit")
+	renderLine = flag.Int("render-line", 0, "the first line of the page -render-to draws")
+
+	concordanceStr = flag.String("concordance", "", "usage: -concordance=<word>, print every sentence with <word> in it and exit")
+)
 
 func main() {
 	flag.Parse()
 
+	const (
+		textDir     string = "./text/"
+		fontDir     string = "./fonts/"
+		lemmaDir    string = "./lemmas/"
+		dictDir     string = "./dict/"
+		defaultFont string = "AnonymousPro-Regular.ttf"
+		defaultText string = "HP01.txt"
+
+		windowW int32 = 640
+		windowH int32 = 480
+	)
+
 	if *cpuprof != "" {
 		cpuf, err := os.Create(*cpuprof)
 		if err != nil {
@@ -57,594 +61,272 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	runtime.LockOSThread()
+	if *listRecent {
+		db := DBOpen()
+		defer db.Close()
 
-	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
-		panic(err)
+		recent, err := DBRecentTexts(db, 10)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, text := range recent {
+			fmt.Println(text)
+		}
+		return
 	}
 
-	window, err := sdl.CreateWindow("", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, 640, 480,
-		sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
-	if err != nil {
-		panic(err)
-	}
+	if *concordanceStr != "" {
+		db := DBOpen()
+		defer db.Close()
 
-	// NOTE: I've heard that PRESENTVSYNC caps FPS
-	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED|sdl.RENDERER_PRESENTVSYNC)
-	if err != nil {
-		panic(err)
+		if _, err := DBBuildConcordance(db, textDir); err != nil {
+			fmt.Println(err)
+			return
+		}
+		hits, err := DBConcordance(db, *concordanceStr, 0)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		sentences, err := ConcordanceSentences(textDir, hits, make(map[string]string))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for i := range sentences {
+			if sentences[i] != "" {
+				fmt.Printf("%s: %s\n", hits[i].File, sentences[i])
+			}
+		}
+		return
 	}
 
-	ticker := time.NewTicker(time.Second / 60)
-
-	sdl.SetHint(sdl.HINT_FRAMEBUFFER_ACCELERATION, "1")
-	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "1")
-
-	renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	textName := defaultText
+	if *textStr != "" {
+		textName = *textStr
+	}
+	fontName := defaultFont
+	if *fontStr != "" {
+		fontName = *fontStr
+	}
 
-	bgrect := sdl.Rect{X: 0, Y: 0, W: 640, H: 480}
-	testTex, err := renderer.CreateTexture(uint32(sdl.PIXELFORMAT_RGBA32), sdl.TEXTUREACCESS_STREAMING, 640, 480)
-	if err != nil {
+	// bold and italic faces are picked up from fontDir by name, every
+	// other font in there is a fallback for the runes fontName doesn't have
+	fontFamily, err := LoadFontFamily(fontDir, fontName, *monoStr)
+	if fontFamily == nil {
 		fmt.Println(err)
 		return
 	}
-	defer testTex.Destroy()
-	testTex.SetBlendMode(sdl.BLENDMODE_BLEND)
-
-	var fontDst string
-	var textDst string
-
-	const (
-		textDir     string = "./text/"
-		fontDir     string = "./fonts/"
-		defaultFont string = "AnonymousPro-Regular.ttf"
-		defaultText string = "HP01.txt"
-	)
-
-	if *textStr == "" {
-		textDst = textDir + defaultText
-	} else {
-		textDst = textDir + *textStr
-	}
-
-	textData, err := ioutil.ReadFile(textDst)
 	if err != nil {
 		fmt.Println(err)
-		return
 	}
+	fmt.Printf("font %s with %d fallbacks\n", fontName, len(fontFamily.Regular.Fallbacks))
 
-	println("[debug] got here!")
+	// the page without a window, see RenderPage
+	if *renderTo != "" {
+		doc, err := OpenDocument(textDir, textName, *langStr, dictDir, int(windowW)-pageMarginRight, charWidth)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		db := DBOpen()
+		defer db.Close()
+		if doc.Highlights, err = DBLoadHighlights(db, doc.Name); err != nil {
+			fmt.Println(err)
+		}
 
-	testTokens := WrapLines(string(textData), 400, 18/2)
+		img := RenderPage(doc, fontFamily, PageOptions{
+			Width: int(windowW), Height: int(windowH),
+			DPI: 72, FontSize: defaultFontSize, StartIndex: *renderLine,
+		})
+		if err := WritePNG(*renderTo, img); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
 
-	println("[debug] got here!")
+	runtime.LockOSThread()
 
-	if *fontStr == "" {
-		fontDst = fontDir + defaultFont
-	} else {
-		fontDst = fontDir + *fontStr
+	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
+		panic(err)
 	}
 
-	fontBytes, err := ioutil.ReadFile(fontDst)
+	window, err := sdl.CreateWindow("", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, windowW, windowH,
+		sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE|sdl.WINDOW_ALLOW_HIGHDPI)
 	if err != nil {
-		fmt.Println(err)
-		return
+		panic(err)
 	}
 
-	parsedFont, err := freetype.ParseFont(fontBytes)
+	// vsync is what keeps animations at the refresh rate, when nothing
+	// moves the loop sleeps in WaitEventTimeout
+	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED|sdl.RENDERER_PRESENTVSYNC)
 	if err != nil {
-		fmt.Println(err)
-		return
+		panic(err)
 	}
 
-	fontSize := float64(18.0)
-
-	fontBGColor := image.NewUniform(color.RGBA{255, 255, 255, 255})
-	fontFGColor := image.NewUniform(color.RGBA{0, 0, 0, 255})
-
-	bg := image.NewRGBA(image.Rect(0, 0, 640, 480))
-
-	draw.Draw(bg, bg.Bounds(), fontBGColor, image.Point{0, 0}, draw.Src)
-
-	ctx := freetype.NewContext()
-	ctx.SetFont(parsedFont)
-	ctx.SetDPI(72)
-	ctx.SetFontSize(fontSize)
-	ctx.SetClip(bg.Bounds())
-	ctx.SetDst(bg)
-	ctx.SetSrc(fontFGColor)
-
-	textWindowOffset := 10
-
-	pt := freetype.Pt(textWindowOffset, 20)
+	sdl.SetHint(sdl.HINT_FRAMEBUFFER_ACCELERATION, "1")
+	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "1")
 
-	var (
-		zoomIn       bool
-		zoomOut      bool
-		moveLineUp   bool
-		moveLineDown bool
-		movePageUp   bool
-		movePageDown bool
+	renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
 
-		mouseButtonClicked           bool
-		mouseButtonClickedAndDragged bool
+	// we draw in drawable pixels, on a HiDPI display there are more of
+	// them than window coordinates, see DisplayScale
+	scale := NewDisplayScale(window, renderer)
 
-		running bool = true
+	// the window manager doesn't have to give us what we asked for
+	winW, winH, err := renderer.GetOutputSize()
+	if err != nil || winW <= 0 || winH <= 0 {
+		winW, winH = windowW, windowH
+	}
 
-		newFontSize float64
-		startIndex  int = 0
-		numLines    int = 24
-	)
+	doc, err := OpenDocument(textDir, textName, *langStr, dictDir, int(winW)-scale.Px(pageMarginRight), scale.Px(charWidth))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	// TODO(read): https://developer.apple.com/fonts/TrueType-Reference-Manual/RM02/Chap2.html#intro
 	// TODO(read): https://golang.hotexamples.com/ru/examples/github.com.golang.freetype.truetype/Font/FUnitsPerEm/golang-font-funitsperem-method-examples.html
 	// TODO(read): https://bit.ly/2kjbenG
 
-	// ---- page allocs ----
-	numAllocs := 0
+	// ----- database test -----
+	db := DBOpen()
+	defer db.Close()
 
-	for i := 0; i < numLines; i++ {
-		numAllocs += CountSpacesBetweenWords(testTokens[i])
+	if err = DBAddRecent(db, doc.Name); err != nil {
+		fmt.Println(err)
 	}
 
-	numAllocs = numAllocs * 2 // alloc size subject to change
-
-	word_rects := make([]WordRects, numAllocs)
-
-	mouse_over := make([]bool, numAllocs)
-	// ---- page allocs ----
-
-	DrawToCtx(bg, ctx, pt, &testTokens, parsedFont, startIndex, numLines, fontSize, &word_rects)
-
-	fmt.Printf("len of page_elem_len_x %d\n", len(word_rects))
-
-	var anim func() bool
-
-	testTex.Update(&bgrect, unsafe.Pointer(&bg.Pix[0]), bg.Stride)
+	lang := *langStr
+	if lang == "" {
+		lang = DetectLanguage(doc.Text)
+		fmt.Printf("language %q, guessed from the text\n", lang)
+	}
+	lem, err := NewLemmatizer(lang, lemmaDir)
+	if err != nil {
+		fmt.Println(err)
+		lem = IdentityLemmatizer{}
+	}
 
-	// ----- database test -----
-	db := DBOpen()
-	defer db.Close()
+	known_word_data := GetUniqueWords(doc.Lines, lem, doc.Segmenter)
+	fmt.Printf("%d unique words, %d ignored as proper nouns\n",
+		len(known_word_data), CountProperNouns(known_word_data))
 
-	known_word_data := GetUniqueWords(testTokens)
+	// words from before they were keyed by lemma go under their lemma
+	if n, err := DBMigrateLemmaKeys(db, doc.Name, lang, known_word_data); err != nil {
+		fmt.Println(err)
+	} else if n > 0 {
+		fmt.Printf("%d words moved to their lemma\n", n)
+	}
 
 	// DB stuff
 	if err = DBInit(db, known_word_data); err != nil {
 		fmt.Printf("Something went wrong %v", err)
 	}
-
-	//if err = DBInsert(db, "hobbit"); err != nil {
-	//	fmt.Printf("Something went wrong %v", err)
-	//}
+	if n, err := DBCountUnknown(db, known_word_data); err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Printf("%d unknown words\n", n)
+	}
 	// ----- database test -----
 
-	hiStartX := int32(textWindowOffset)
-	hiStartY := int32(0) // int32(ctx.PointToFixed(fontSize))
+	app := NewApp(doc, fontFamily, db, lem, int(winW), int(winH), scale)
+	app.textDir = textDir
+	app.showHUD = *hudFlag
+	if keys, err := LoadKeymap(*keysStr); err != nil {
+		fmt.Println(err)
+		fmt.Println("using the default keys")
+	} else {
+		app.keys = keys
+	}
+	if *vimFlag {
+		app.Do(Action{Kind: ActionVim, N: -1})
+	}
 
-	// + 1 because we have a 0 based indexing
-	hiRects := NewHiLineRects(numLines+1, hiStartX, hiStartY)
+	// the text is drawn from the atlas on top of the page, see GlyphAtlas
+	if err := app.atlas.CreateTexture(renderer); err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer app.atlas.Destroy()
+
+	screen, err := NewScreen(window, renderer, app)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer screen.Destroy()
 
 	var (
-		clearScreen    bool
-		word_rect_indx int = -1
-		selectedLine   int32
+		stats      FrameStats
+		lastReport = time.Now()
 	)
 
-	notYetSet := true
-	var startSelectionRange int
-
-	highlighted := false
-	testSelect := false
-
-	testSelectIndexStart := 0
-	testSelectIndexEnd := 0
-	testSelectYCoord := 0
-
-	for running {
-		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			switch t := event.(type) {
-			case *sdl.QuitEvent:
-				running = false
-			case *sdl.MouseMotionEvent:
-				MouseOverWords(t, ctx, &word_rects, &mouse_over)
-
-				// check go doc sdl.MouseMotionEvent for solution
-				if testSelect {
-					testSelectIndexEnd = int(t.X)
-					mouseButtonClickedAndDragged = true
-				}
-				if mouseButtonClickedAndDragged {
-					testSelectYCoord = int(t.Y)
-				}
-			case *sdl.MouseWheelEvent:
-				switch {
-				case t.Y > 0:
-					moveLineUp = true
-				case t.Y < 0:
-					moveLineDown = true
-				}
-			case *sdl.MouseButtonEvent:
-				switch t.Type {
-				case sdl.MOUSEBUTTONDOWN:
-				case sdl.MOUSEBUTTONUP:
-					mouseButtonClicked = true
-				}
-
-				if !testSelect && t.Type == sdl.MOUSEBUTTONDOWN && t.State == sdl.PRESSED {
-					testSelect = true
-					testSelectIndexStart = int(t.X)
-					highlighted = false
-				}
-
-				if testSelect && t.Type == sdl.MOUSEBUTTONUP && t.State == sdl.RELEASED {
-					testSelect = false
-					highlighted = true
-				}
-			case *sdl.KeyboardEvent:
-				if t.Keysym.Sym == sdl.K_ESCAPE {
-					running = false
-				}
-
-				switch t.Type {
-				case sdl.KEYDOWN:
-				case sdl.KEYUP:
-					switch t.Keysym.Sym {
-					case sdl.K_f:
-						if !zoomIn {
-							zoomIn = true
-							newFontSize = fontSize + 2.0
-						}
-					case sdl.K_b:
-						if !zoomOut {
-							zoomOut = true
-							newFontSize = fontSize - 2.0
-						}
-					case sdl.K_UP:
-						moveLineUp = true
-					case sdl.K_DOWN:
-						moveLineDown = true
-					case sdl.K_LEFT:
-						movePageUp = true
-					case sdl.K_RIGHT:
-						movePageDown = true
-					}
-				}
-			default:
+	for app.running {
+		// nothing is moving and nothing changed since the last frame, the
+		// screen is what it should be until something happens
+		event := sdl.PollEvent()
+		if event == nil && app.Idle() {
+			app.updates.Reset()
+			if event = sdl.WaitEventTimeout(idleWait); event == nil {
 				continue
 			}
 		}
-		renderer.SetDrawColor(255, 255, 255, 255)
-		renderer.Clear()
-
-		renderer.Copy(testTex, nil, &bgrect)
-
-		if mouseButtonClicked {
-			for i := 0; i < len(mouse_over); i++ {
-				if mouse_over[i] == true && word_rect_indx != i {
-					if word_rect_indx < 0 { // guard against -1 index
-						word_rect_indx = 0
-					}
-
-					// clear
-					colorG := image.NewUniform(color.RGBA{0, 255, 0, 108})
-					draw.Draw(bg, word_rects[word_rect_indx].Rect, colorG, image.Point{0, 0}, draw.Src)
-					testTex.Update(&bgrect, unsafe.Pointer(&bg.Pix[0]), bg.Stride)
-
-					// draw
-					colorB := image.NewUniform(color.RGBA{0, 0, 244, 108})
-					draw.Draw(bg, word_rects[i].Rect, colorB, image.Point{0, 0}, draw.Src)
-					testTex.Update(&bgrect, unsafe.Pointer(&bg.Pix[0]), bg.Stride)
-
-					word_rect_indx = i
-				}
-
-				if mouse_over[i] == true && word_rect_indx == i {
-					w := GetWord(&testTokens, &word_rects, word_rect_indx)
-					exists, err := DBView(db, w)
-					if err != nil {
-						fmt.Println(err)
-					}
-
-					const msg = "'%s' exists in the database = %q\n"
-					fmt.Printf(msg, w, exists)
-
-					clearScreen = false
-					break
-				}
-
-				if word_rect_indx >= 0 {
-					clearScreen = true
-				}
-			}
-			mouseButtonClicked = false
+		frameStart := time.Now()
+		app.pageChanged = false
+		app.layoutTime = 0
+		rasterStart := app.atlas.RasterTime
+		var allocStart uint64
+		if app.showHUD {
+			allocStart = HeapAllocs()
 		}
 
-		if mouseButtonClickedAndDragged {
-			start := GetSelectedCharLen(testSelectIndexStart)
-			if start > 0 {
-				start -= 1
-			}
-			end := GetSelectedCharLen(testSelectIndexEnd)
-
-			lineHeight := ctx.PointToFixed(fontSize).Round()
-			// hiRects.rects[selectedLine].H = int32(lineHeight)
-
-			selectedLine = int32(YCoordToNumLines(testSelectYCoord, lineHeight))
-
-			markLast := false
-			wentDown := false
-
-			if hiRects.currln < selectedLine {
-				hiRects.currln = selectedLine
-				wentDown = true
-			} else if hiRects.currln > selectedLine {
-				markLast = true
-			}
-
-			var lineY int
-			lineY = lineHeight * (int(selectedLine) + 1)
-
-			// used for selecting testToken string after movePageDown/Up moveLineUp/Down
-			startIndex32 := int32(startIndex)
-
-			if selectedLine <= int32(numLines) {
-				println(selectedLine)
-				hiRects.rects[selectedLine].Y = int32(lineY - textWindowOffset)
-				// hiRects.rects[selectedLine].H = int32(lineHeight)
-
-				// TODO
-				// we are already calling WidthOfString all over our codebase, so wouldn't
-				// it be better to somehow memoize the results so that we don't have to call it as much?
-				maxWidth := int(WidthOfString(parsedFont, fontSize, testTokens[selectedLine+startIndex32]))
-
-				// we need to track testSelectIndexStart
-				// (!) we can probably solve our selection problems by using testSelectIndexStart
-				if testSelectIndexStart >= textWindowOffset && testSelectIndexStart <= maxWidth && notYetSet {
-					// fmt.Printf("ok... we got our position x: %d\n", testSelectIndexStart)
-					hiRects.rects[selectedLine].X = int32(testSelectIndexStart)
-					notYetSet = false
-					startSelectionRange = int(selectedLine)
-				}
-
-				if testSelectIndexEnd < maxWidth && !wentDown {
-					hiRects.rects[selectedLine].W = int32(testSelectIndexEnd) - hiRects.rects[selectedLine].X
-				} else if testSelectIndexEnd < maxWidth && wentDown || testSelectIndexEnd >= maxWidth && wentDown {
-					prevSelectedLine := selectedLine
-					if prevSelectedLine > 0 {
-						prevSelectedLine -= 1
-					}
-					maxWidth = int(WidthOfString(parsedFont, fontSize, testTokens[prevSelectedLine+startIndex32]))
-					hiRects.rects[prevSelectedLine].W = int32(maxWidth) - hiRects.rects[selectedLine-1].X
-
-					// this is why we couldn't properly render maxWidth when we start at a latter X
-					// it's because we need to select the prevLine instead of selectedLine!
-					// prevLine == selectedLine-1
-					// println(maxWidth, int32(maxWidth) - hiRects.rects[selectedLine-1].X, hiRects.rects[selectedLine-1].X)
-				} else if testSelectIndexEnd > maxWidth {
-					hiRects.rects[selectedLine].W = int32(maxWidth)
-				}
-
-				if markLast {
-					hiRects.UnShowRangeFrom(int(selectedLine))
-					hiRects.currln = selectedLine
-					markLast = false
-				}
-
-				// loop through and select range from start up to selectedLine
-				// so that we catch any lines that were missed
-				hiRects.Show(startSelectionRange, int(selectedLine))
-
-				for i := int32(0); i < selectedLine; i++ {
-					if hiRects.IsShown(int(i)) {
-						// we don't set rects[i].W on selection start
-						if i == int32(startSelectionRange) {
-							draw_rect_without_border(renderer, &hiRects.rects[i], &sdl.Color{R: 200, G: 100, B: 80, A: 100})
-							continue
-						}
-						// the following two lines are needed to set the Y properly
-						lineY = lineHeight * (int(i) + 1)
-						hiRects.rects[i].Y = int32(lineY - textWindowOffset)
-
-						hiRects.rects[i].W = int32(WidthOfString(parsedFont, fontSize, testTokens[i+startIndex32]))
-						draw_rect_without_border(renderer, &hiRects.rects[i], &sdl.Color{R: 200, G: 100, B: 80, A: 100})
-					}
+		for ; event != nil; event = sdl.PollEvent() {
+			if t, ok := event.(*sdl.WindowEvent); ok {
+				switch t.Event {
+				case sdl.WINDOWEVENT_SIZE_CHANGED, sdl.WINDOWEVENT_DISPLAY_CHANGED:
+					screen.Resize(app)
 				}
+				continue
 			}
-
-			if highlighted {
-				max_str_len := len(testTokens[selectedLine+startIndex32])
-
-				// completely broken!
-				// this branch is not guaranteed to be bug free (IT HAS TO BE TESTED!)
-				// switch {case:, case:, ...} also has to be tested!
-				if start < max_str_len && end < max_str_len {
-					switch {
-					case end > 0:
-						println("case: end > 0")
-						fmt.Println(testTokens[selectedLine+startIndex32][start:end])
-					case end == 0:
-						println("case: end == 0")
-						fmt.Println(testTokens[selectedLine+startIndex32][start : start+1])
-					case end < start:
-						println("case: end < start")
-						fmt.Println(testTokens[selectedLine+startIndex32][start : start+end])
-					case end+start >= max_str_len:
-						println("case: end + start >= max_str_len")
-						fmt.Println(testTokens[selectedLine+startIndex32][start:])
-					// (!) not sure about this one
-					case end == start:
-						println("case: end == start")
-						fmt.Println(testTokens[selectedLine+startIndex32][start : start+1])
-					// (!) not sure about this one
-					case end == 0 && start == 0:
-						println("case: end == 0 && start == 0")
-						break
-					}
-				}
-
-				// reset
-				notYetSet = true
-				startSelectionRange = 0
-				mouseButtonClickedAndDragged = false
-				hiRects.UnShowAllAndReset(hiStartX)
-			}
-		}
-
-		if clearScreen {
-			colorG := image.NewUniform(color.RGBA{0, 255, 0, 108})
-			draw.Draw(bg, word_rects[word_rect_indx].Rect, colorG, image.Point{0, 0}, draw.Src)
-			testTex.Update(&bgrect, unsafe.Pointer(&bg.Pix), bg.Stride)
-			word_rect_indx = -1
-			clearScreen = false
-		}
-
-		if movePageUp {
-			movePageUp = false
-			if startIndex-numLines < 0 {
-				startIndex = 0
-			} else if startIndex > 0 {
-				startIndex -= numLines
-			}
-
-			// clear
-			draw.Draw(bg, bg.Bounds(), fontBGColor, image.Point{0, 0}, draw.Src)
-
-			// redraw with increased fontSize and update
-			ctx.SetFontSize(fontSize)
-
-			// do we need to call freetype.Pt() here? Can't we just pt.X, pt.Y = ?, ?
-			pt = freetype.Pt(textWindowOffset, 20)
-
-			DrawToCtx(bg, ctx, pt, &testTokens, parsedFont, startIndex, numLines, fontSize, &word_rects)
-
-			testTex.Update(&bgrect, unsafe.Pointer(&bg.Pix), bg.Stride)
-		}
-
-		if moveLineUp {
-			moveLineUp = false
-			if startIndex > 0 {
-				startIndex -= 1
-			}
-
-			// clear
-			draw.Draw(bg, bg.Bounds(), fontBGColor, image.Point{0, 0}, draw.Src)
-
-			// redraw with increased fontSize and update
-			ctx.SetFontSize(fontSize)
-
-			// do we need to call freetype.Pt() here? Can't we just pt.X, pt.Y = ?, ?
-			pt = freetype.Pt(textWindowOffset, 20)
-
-			DrawToCtx(bg, ctx, pt, &testTokens, parsedFont, startIndex, numLines, fontSize, &word_rects)
-
-			testTex.Update(&bgrect, unsafe.Pointer(&bg.Pix), bg.Stride)
-		}
-
-		if movePageDown {
-			movePageDown = false
-
-			if startIndex >= 0 {
-				startIndex += numLines
-			}
-
-			// clear
-			draw.Draw(bg, bg.Bounds(), fontBGColor, image.Point{0, 0}, draw.Src)
-
-			// redraw with increased fontSize and update
-			ctx.SetFontSize(fontSize)
-
-			// do we need to call freetype.Pt() here? Can't we just pt.X, pt.Y = ?, ?
-			pt = freetype.Pt(textWindowOffset, 20)
-
-			DrawToCtx(bg, ctx, pt, &testTokens, parsedFont, startIndex, numLines, fontSize, &word_rects)
-
-			testTex.Update(&bgrect, unsafe.Pointer(&bg.Pix), bg.Stride)
+			app.HandleEvent(event)
 		}
 
-		if moveLineDown {
-			moveLineDown = false
-			startIndex += 1
-
-			// clear
-			draw.Draw(bg, bg.Bounds(), fontBGColor, image.Point{0, 0}, draw.Src)
-
-			// redraw with increased fontSize and update
-			ctx.SetFontSize(fontSize)
-
-			// do we need to call freetype.Pt() here? Can't we just pt.X, pt.Y = ?, ?
-			pt = freetype.Pt(textWindowOffset, 20)
-
-			DrawToCtx(bg, ctx, pt, &testTokens, parsedFont, startIndex, numLines, fontSize, &word_rects)
-
-			testTex.Update(&bgrect, unsafe.Pointer(&bg.Pix[0]), bg.Stride)
+		app.Update(frameStart)
+		screen.Render(app)
+
+		frameTime := time.Since(frameStart)
+		stats.Add(frameTime)
+		if app.showHUD {
+			screen.hud.Add(FramePerf{
+				Total:  frameTime,
+				Layout: app.layoutTime,
+				Raster: app.atlas.RasterTime - rasterStart,
+				Upload: screen.uploadTime,
+				Allocs: HeapAllocs() - allocStart,
+			})
+			screen.hud.Update(time.Now(), shapeCache, app.atlas)
+			screen.hud.Present(renderer)
 		}
-
-		if zoomIn {
-			if anim == nil {
-				anim = EasingAnimate(&fontSize, newFontSize, EaseInOutQuad, "animIn")
-			}
-
-			fmt.Println(fontSize)
-			if !anim() {
-				anim = nil
-				zoomIn = false
-				println("END of animIn")
-			}
-
-			// clear
-			draw.Draw(bg, bg.Bounds(), fontBGColor, image.Point{0, 0}, draw.Src)
-
-			// redraw with increased fontSize and update
-			ctx.SetFontSize(fontSize)
-
-			// do we need to call freetype.Pt() here? Can't we just pt.X, pt.Y = ?, ?
-			pt = freetype.Pt(textWindowOffset, 20)
-
-			DrawToCtx(bg, ctx, pt, &testTokens, parsedFont, startIndex, numLines, fontSize, &word_rects)
-
-			testTex.Update(&bgrect, unsafe.Pointer(&bg.Pix[0]), bg.Stride)
+		if *frameTimes && time.Since(lastReport) >= time.Second {
+			fmt.Println(stats.String())
+			lastReport = time.Now()
 		}
 
-		if zoomOut {
-			if anim == nil {
-				anim = EasingAnimate(&fontSize, newFontSize, EaseInOutQuad, "animOut")
-			}
-
-			fmt.Println(fontSize)
-			if !anim() {
-				anim = nil
-				zoomOut = false
-				println("END of animOut")
-			}
-
-			// clear
-			draw.Draw(bg, bg.Bounds(), fontBGColor, image.Point{0, 0}, draw.Src)
-
-			// redraw with increased fontSize and update
-			ctx.SetFontSize(fontSize)
-
-			// do we need to call freetype.Pt() here? Can't we just pt.X, pt.Y = ?, ?
-			pt = freetype.Pt(textWindowOffset, 20)
-
-			DrawToCtx(bg, ctx, pt, &testTokens, parsedFont, startIndex, numLines, fontSize, &word_rects)
-
-			testTex.Update(&bgrect, unsafe.Pointer(&bg.Pix[0]), bg.Stride)
-		}
+		renderer.Present()
+	}
 
-		// we don't have to do this on every frame
-		// figure out a way to make it more efficient.
-		// use t := time.Now()
-		// 1) https://gameprogrammingpatterns.com/game-loop.html
-		// 2) https://bell0bytes.eu/the-game-loop//
-		// ...
+	if *frameTimes {
+		fmt.Println(stats.String())
+	}
 
-		renderer.Present()
-		<-ticker.C
+	if err = DBSavePosition(db, doc.Name, doc.LineOffset(app.startIndex)); err != nil {
+		fmt.Println(err)
 	}
 
 	// why aren't we defer'ring these?
 	sdl.Quit()
-	ticker.Stop()
 	renderer.Destroy()
 	window.Destroy()
 	runtime.UnlockOSThread()
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// an opening run of '*' or '_' that's waiting for its closing run
+type emphasisDelim struct {
+	pos int // where the run starts in src
+	n   int // how much of it is still unused
+	c   byte
+}
+
+// ParseMarkdownEmphasis strips **bold**, *italic* (or with '_') and
+// `code` markers out of src, the styles it returns are offsets into the
+// stripped text. It's not a full markdown parser, headings, links and
+// lists are left alone. Markers that don't have a pair stay in the text.
+func ParseMarkdownEmphasis(src string) (string, []StyleSpan) {
+	var (
+		removed []Span      // bytes of src that are markers
+		styled  []StyleSpan // offsets into src for now
+		stack   []emphasisDelim
+	)
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n' && strings.HasPrefix(src[i:], "\n\n"):
+			stack = stack[:0] // emphasis doesn't go across paragraphs
+			i++
+			continue
+		case c == '`':
+			n := runLength(src, i)
+			fence := src[i : i+n]
+			end := strings.Index(src[i+n:], fence)
+			if end < 0 {
+				i += n
+				continue
+			}
+			end += i + n
+			removed = append(removed, Span{Start: i, End: i + n}, Span{Start: end, End: end + n})
+			styled = append(styled, StyleSpan{Start: i + n, End: end, Style: StyleMono})
+			i = end + n
+			continue
+		case c != '*' && c != '_':
+			i++
+			continue
+		}
+
+		n := runLength(src, i)
+		before, _ := utf8.DecodeLastRuneInString(src[:i])
+		after, _ := utf8.DecodeRuneInString(src[i+n:])
+		canOpen := i+n < len(src) && !unicode.IsSpace(after)
+		canClose := i > 0 && !unicode.IsSpace(before)
+		if c == '_' { // snake_case isn't emphasis
+			canOpen = canOpen && (i == 0 || !isWordRune(before))
+			canClose = canClose && (i+n == len(src) || !isWordRune(after))
+		}
+
+		pos := i
+		for canClose && n > 0 {
+			k := len(stack) - 1
+			for k >= 0 && stack[k].c != c {
+				k--
+			}
+			if k < 0 {
+				break
+			}
+			open := &stack[k]
+			use := minInt(open.n, n)
+			if use > 3 {
+				use = 3
+			}
+			var style FontStyle
+			switch use {
+			case 1:
+				style = StyleItalic
+			case 2:
+				style = StyleBold
+			default:
+				style = StyleBold | StyleItalic
+			}
+			openEnd := open.pos + open.n
+			removed = append(removed, Span{Start: openEnd - use, End: openEnd}, Span{Start: pos, End: pos + use})
+			styled = append(styled, StyleSpan{Start: openEnd, End: pos, Style: style})
+
+			open.n -= use
+			stack = stack[:k+1]
+			if open.n == 0 {
+				stack = stack[:k]
+			}
+			pos += use
+			n -= use
+		}
+		if canOpen && n > 0 {
+			stack = append(stack, emphasisDelim{pos: pos, n: n, c: c})
+		}
+		i = pos + n
+	}
+
+	if len(removed) == 0 {
+		return src, styled
+	}
+
+	// offsets[i] is where byte i of src ends up
+	sort.Slice(removed, func(a, b int) bool { return removed[a].Start < removed[b].Start })
+	offsets := make([]int, len(src)+1)
+	var b strings.Builder
+	r := 0
+	for i := 0; i <= len(src); i++ {
+		offsets[i] = b.Len()
+		for r < len(removed) && removed[r].End <= i {
+			r++
+		}
+		if i < len(src) && (r == len(removed) || i < removed[r].Start) {
+			b.WriteByte(src[i])
+		}
+	}
+
+	var styles []StyleSpan
+	for _, st := range styled {
+		st.Start, st.End = offsets[st.Start], offsets[st.End]
+		if st.Start < st.End {
+			styles = append(styles, st)
+		}
+	}
+	sort.Slice(styles, func(a, b int) bool {
+		if styles[a].Start != styles[b].Start {
+			return styles[a].Start < styles[b].Start
+		}
+		return styles[a].End < styles[b].End
+	})
+	return b.String(), styles
+}
+
+// how many times src[i] repeats starting at i
+func runLength(src string, i int) int {
+	n := 1
+	for i+n < len(src) && src[i+n] == src[i] {
+		n++
+	}
+	return n
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseMarkdownEmphasis(t *testing.T) {
+	type test struct {
+		in     string
+		out    string
+		styles []StyleSpan
+	}
+
+	tests := []test{
+		{in: "no markup here", out: "no markup here"},
+		{in: "a *b* c", out: "a b c", styles: []StyleSpan{{2, 3, StyleItalic}}},
+		{in: "a **bold** c", out: "a bold c", styles: []StyleSpan{{2, 6, StyleBold}}},
+		{in: "_it_ and __bo__", out: "it and bo", styles: []StyleSpan{{0, 2, StyleItalic}, {7, 9, StyleBold}}},
+		{in: "***both***", out: "both", styles: []StyleSpan{{0, 4, StyleBold | StyleItalic}}},
+		{in: "***a** b*", out: "a b", styles: []StyleSpan{{0, 1, StyleBold}, {0, 3, StyleItalic}}},
+		{in: "call `f(*x*)` now", out: "call f(*x*) now", styles: []StyleSpan{{5, 11, StyleMono}}},
+		// none of these are emphasis
+		{in: "snake_case_name", out: "snake_case_name"},
+		{in: "2 * 3 * 4", out: "2 * 3 * 4"},
+		{in: "*open", out: "*open"},
+		{in: "*one\n\nother*", out: "*one\n\nother*"},
+		{in: "`unclosed", out: "`unclosed"},
+		{in: "жирный **шрифт**", out: "жирный шрифт", styles: []StyleSpan{{13, 23, StyleBold}}},
+	}
+
+	for ntest, tt := range tests {
+		out, styles := ParseMarkdownEmphasis(tt.in)
+		if out != tt.out || len(styles) != len(tt.styles) {
+			t.Errorf("ntest: %d, got: %q %v, want %q %v\n", ntest, out, styles, tt.out, tt.styles)
+			continue
+		}
+		for i := range styles {
+			if styles[i] != tt.styles[i] {
+				t.Errorf("ntest: %d, got: %v, want %v\n", ntest, styles, tt.styles)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// how many glyphs the shaping cache holds before it starts dropping the
+// least recently used strings, a glyph is about 64 bytes so this is ~16MB
+const shapeCacheGlyphs = 1 << 18
+
+// shapeCache is what Font.Shape goes through, everything that measures
+// or lays out text (WidthOfString, CharWidths, Document.Layout and so
+// selection and hit testing) ends up here. In a novel the same words and
+// short runs come up over and over, and shaping is the slow part.
+var shapeCache = NewShapeCache(shapeCacheGlyphs)
+
+type shapeKey struct {
+	font *Font
+	size fixed.Int26_6
+	rtl  bool
+	text string
+}
+
+type shapeEntry struct {
+	key    shapeKey
+	glyphs []ShapedGlyph
+}
+
+// ShapeCache is an LRU of shaped strings, its size is counted in glyphs
+// (plus one per entry, so that empty strings aren't free) rather than in
+// strings because one long line costs as much as a hundred short words.
+type ShapeCache struct {
+	mu      sync.Mutex
+	max     int
+	size    int
+	order   *list.List // front is the most recently used
+	entries map[shapeKey]*list.Element
+
+	Hits, Misses int
+}
+
+func NewShapeCache(max int) *ShapeCache {
+	return &ShapeCache{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[shapeKey]*list.Element),
+	}
+}
+
+func (c *ShapeCache) Get(key shapeKey) ([]ShapedGlyph, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.Misses++
+		return nil, false
+	}
+	c.Hits++
+	c.order.MoveToFront(e)
+	return e.Value.(*shapeEntry).glyphs, true
+}
+
+func (c *ShapeCache) Put(key shapeKey, glyphs []ShapedGlyph) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cost := len(glyphs) + 1
+	if cost > c.max {
+		return // would push everything else out
+	}
+	if e, ok := c.entries[key]; ok {
+		c.size += cost - len(e.Value.(*shapeEntry).glyphs) - 1
+		e.Value.(*shapeEntry).glyphs = glyphs
+		c.order.MoveToFront(e)
+	} else {
+		c.entries[key] = c.order.PushFront(&shapeEntry{key: key, glyphs: glyphs})
+		c.size += cost
+	}
+	for c.size > c.max {
+		last := c.order.Back()
+		entry := last.Value.(*shapeEntry)
+		c.order.Remove(last)
+		delete(c.entries, entry.key)
+		c.size -= len(entry.glyphs) + 1
+	}
+}
+
+func (c *ShapeCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *ShapeCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[shapeKey]*list.Element)
+	c.size = 0
+}
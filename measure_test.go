@@ -0,0 +1,150 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestShapeCacheLRU(t *testing.T) {
+	c := NewShapeCache(10)
+	glyphs := func(n int) []ShapedGlyph { return make([]ShapedGlyph, n) }
+	key := func(s string) shapeKey { return shapeKey{text: s} }
+
+	c.Put(key("a"), glyphs(3)) // costs 4
+	c.Put(key("b"), glyphs(3))
+	if _, ok := c.Get(key("a")); !ok { // a is now newer than b
+		t.Fatalf("a is missing")
+	}
+	c.Put(key("c"), glyphs(3)) // 12 > 10, b goes
+
+	type test struct {
+		in  string
+		out bool
+	}
+
+	tests := []test{
+		{in: "a", out: true},
+		{in: "b", out: false},
+		{in: "c", out: true},
+	}
+
+	for ntest, tt := range tests {
+		if _, ok := c.Get(key(tt.in)); ok != tt.out {
+			t.Errorf("ntest: %d, %q cached: %v, want %v\n", ntest, tt.in, ok, tt.out)
+		}
+	}
+
+	const msg = "ntest: %d, got: %d, want %d\n"
+	if c.size != 8 || c.Len() != 2 {
+		t.Errorf(msg, 0, c.size, 8)
+	}
+	// too big to ever fit, it shouldn't empty the cache
+	c.Put(key("d"), glyphs(20))
+	if c.Len() != 2 {
+		t.Errorf(msg, 1, c.Len(), 2)
+	}
+	c.Clear()
+	if c.Len() != 0 || c.size != 0 {
+		t.Errorf(msg, 2, c.Len(), 0)
+	}
+}
+
+func TestShapeUsesCache(t *testing.T) {
+	font := testFont(t)
+	hits := shapeCache.Hits
+
+	a := font.Shape("cached words", 17, false)
+	b := font.Shape("cached words", 17, false)
+	if &a[0] != &b[0] || shapeCache.Hits != hits+1 {
+		t.Errorf("second Shape wasn't a cache hit")
+	}
+	// a different size is a different entry
+	if c := font.Shape("cached words", 18, false); c[1].X == a[1].X {
+		t.Errorf("got the 17px glyphs for 18px")
+	}
+}
+
+// a made up novel, ~90k words with a zipf-ish vocabulary so that the
+// common words come up about as often as they do in real books
+func testNovel() string {
+	r := rand.New(rand.NewSource(1))
+	letters := "etaoinshrdlucmfwypvbgkqjxz"
+	vocab := make([]string, 20000)
+	for i := range vocab {
+		n := 2 + r.Intn(9)
+		var b strings.Builder
+		for j := 0; j < n; j++ {
+			b.WriteByte(letters[int(r.ExpFloat64()*4)%len(letters)])
+		}
+		vocab[i] = b.String()
+	}
+	zipf := rand.NewZipf(r, 1.1, 1, uint64(len(vocab)-1))
+
+	var b strings.Builder
+	for i := 0; i < 90000; i++ {
+		b.WriteString(vocab[zipf.Uint64()])
+		switch {
+		case i%150 == 149:
+			b.WriteString(".\n")
+		case i%12 == 11:
+			b.WriteString(". ")
+		default:
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+func benchmarkWords(b *testing.B, cached bool) {
+	font, err := ParseFont(goregular.TTF)
+	if err != nil {
+		b.Fatal(err)
+	}
+	words := strings.Fields(testNovel())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		shapeCache.Clear()
+		for _, w := range words {
+			if cached {
+				WidthOfString(font, 18, w)
+			} else {
+				roundPx(shapedWidth(font.shape(w, 18, false)))
+			}
+		}
+	}
+}
+
+func BenchmarkWidthOfStringNovel(b *testing.B)         { benchmarkWords(b, true) }
+func BenchmarkWidthOfStringNovelUncached(b *testing.B) { benchmarkWords(b, false) }
+
+// zooming in and back out on every page of the book, the document only
+// keeps the layouts for one size so everything else is up to the cache
+func benchmarkLayout(b *testing.B, cached bool) {
+	const pageLines = 25
+	font, err := ParseFont(goregular.TTF)
+	if err != nil {
+		b.Fatal(err)
+	}
+	fam := NewFontFamily(font)
+	doc := NewDocument("novel", testNovel(), 400, 9, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		shapeCache.Clear()
+		for page := 0; page < len(doc.Lines); page += pageLines {
+			for _, size := range []float64{18, 20, 18, 20} {
+				if !cached {
+					shapeCache.Clear()
+				}
+				for n := page; n < page+pageLines && n < len(doc.Lines); n++ {
+					doc.Layout(n, fam, size)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkLayoutNovel(b *testing.B)         { benchmarkLayout(b, true) }
+func BenchmarkLayoutNovelUncached(b *testing.B) { benchmarkLayout(b, false) }
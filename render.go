@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/golang/freetype"
+)
+
+// how the page is laid out, in pixels of a 72 DPI screen, the reader
+// scales them with DisplayScale
+const (
+	pageMarginLeft  = 10
+	pageMarginTop   = 20  // the baseline of the first line
+	pageMarginRight = 240 // the bookmark panel goes there
+	charWidth       = 18 / 2
+	defaultFontSize = 18.0 // points
+)
+
+var (
+	pageBGColor = image.NewUniform(color.RGBA{255, 255, 255, 255})
+	pageFGColor = image.NewUniform(color.RGBA{0, 0, 0, 255})
+)
+
+// NewPageContext is the freetype.Context a page is drawn with, fontSize
+// is in points and dpi is what turns them into pixels
+func NewPageContext(fam *FontFamily, dst *image.RGBA, dpi, fontSize float64) *freetype.Context {
+	ctx := freetype.NewContext()
+	ctx.SetFont(fam.Regular.Font)
+	ctx.SetDPI(dpi)
+	ctx.SetFontSize(fontSize)
+	ctx.SetClip(dst.Bounds())
+	ctx.SetDst(dst)
+	ctx.SetSrc(pageFGColor)
+	return ctx
+}
+
+// OpenDocument reads dir+name and wraps it to width, markdown emphasis
+// in .md files ends up in Styles instead of in the text
+func OpenDocument(dir, name, lang, dictDir string, width, charW int) (*Document, error) {
+	data, err := ioutil.ReadFile(dir + name)
+	if err != nil {
+		return nil, err
+	}
+
+	seg, err := NewSegmenter(lang, dictDir)
+	if err != nil {
+		fmt.Println(err)
+		seg = SpaceSegmenter{}
+	}
+
+	text, styles := string(data), []StyleSpan(nil)
+	if strings.HasSuffix(strings.ToLower(name), ".md") {
+		text, styles = ParseMarkdownEmphasis(text)
+	}
+	doc := NewDocument(name, text, width, charW, seg)
+	doc.Styles = styles
+	return doc, nil
+}
+
+// PageOptions is everything about a page that isn't in the Document
+type PageOptions struct {
+	Width, Height int
+	DPI           float64 // 72 is one pixel per point
+	FontSize      float64 // points
+	StartIndex    int     // the first line
+}
+
+// RenderPage draws a page the way the reader shows it (background,
+// highlights, word rects and the text) into a plain image, without SDL.
+// The text goes through a GlyphAtlas like on the screen, its quads are
+// drawn with DrawTo. The overlays aren't part of it. doc.Left is set to
+// the margin at opts.DPI.
+func RenderPage(doc *Document, fam *FontFamily, opts PageOptions) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	draw.Draw(img, img.Bounds(), pageBGColor, image.Point{0, 0}, draw.Src)
+
+	scale := opts.DPI / 72
+	px := func(v int) int { return int(float64(v)*scale + 0.5) }
+	ctx := NewPageContext(fam, img, opts.DPI, opts.FontSize)
+	top := px(pageMarginTop)
+	numLines := PageLines(opts.Height, top, ctx.PointToFixed(opts.FontSize).Round())
+
+	var rects []WordRects
+	doc.Left = px(pageMarginLeft)
+	pt := freetype.Pt(doc.Left, top)
+	DrawPageRects(img, ctx, pt, doc, fam, opts.StartIndex, numLines, opts.FontSize, &rects)
+	atlas := NewGlyphAtlas()
+	atlas.SetPage(doc, fam, pt, opts.StartIndex, numLines, PixelSize(ctx, opts.FontSize), ctx.PointToFixed(opts.FontSize))
+	atlas.DrawTo(img, 0)
+	return img
+}
+
+func WritePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+var updateGolden = flag.Bool("update", false, "write the pages the golden tests render to testdata/golden")
+
+// the rasterizer works in float32 and other architectures (arm64, ppc64)
+// fuse the multiply-adds, so the edges of the glyphs can come out a bit
+// lighter or darker there. Anything the layout gets wrong moves far more
+// pixels than this.
+const (
+	goldenTolerance = 16  // how far apart two channels can be and still be the same
+	goldenMaxDiff   = 0.1 // percent of pixels that can be different
+)
+
+func testFamily(t *testing.T) *FontFamily {
+	t.Helper()
+	parse := func(ttf []byte) *Font {
+		font, err := ParseFont(ttf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return font
+	}
+	return &FontFamily{
+		Regular:    parse(goregular.TTF),
+		Bold:       parse(gobold.TTF),
+		Italic:     parse(goitalic.TTF),
+		BoldItalic: parse(gobolditalic.TTF),
+		Mono:       parse(gomono.TTF),
+	}
+}
+
+// diffPixels is how many pixels of a and b are further apart than
+// goldenTolerance in any channel
+func diffPixels(a, b *image.RGBA) int {
+	n := 0
+	for y := a.Rect.Min.Y; y < a.Rect.Max.Y; y++ {
+		for x := a.Rect.Min.X; x < a.Rect.Max.X; x++ {
+			pa := a.Pix[a.PixOffset(x, y):][:4]
+			pb := b.Pix[b.PixOffset(x, y):][:4]
+			for c := 0; c < 4; c++ {
+				d := int(pa[c]) - int(pb[c])
+				if d > goldenTolerance || d < -goldenTolerance {
+					n++
+					break
+				}
+			}
+		}
+	}
+	return n
+}
+
+func decodePNG(r io.Reader) (*image.RGBA, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba, nil
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			rgba.Set(x, y, img.At(x, y))
+		}
+	}
+	return rgba, nil
+}
+
+const goldenText = `It was a bright cold day in April, and the clocks were striking thirteen.
+Winston Smith, his chin nuzzled into his breast in an effort to escape the vile wind, slipped quickly through the glass doors of Victory Mansions.`
+
+func TestRenderGolden(t *testing.T) {
+	fam := testFamily(t)
+
+	tests := []struct {
+		name string
+		doc  func() *Document
+		opts PageOptions
+	}{
+		{"plain", func() *Document {
+			return NewDocument("plain", goldenText, 320-pageMarginRight/2, charWidth, nil)
+		}, PageOptions{Width: 320, Height: 200, DPI: 72, FontSize: defaultFontSize}},
+		{"highlights", func() *Document {
+			doc := NewDocument("highlights", goldenText, 320-pageMarginRight/2, charWidth, nil)
+			doc.Highlights = []Highlight{
+				{Start: 9, End: 26, Color: HighlightYellow},
+				{Start: 90, End: 110, Color: HighlightYellow},
+			}
+			return doc
+		}, PageOptions{Width: 320, Height: 200, DPI: 72, FontSize: defaultFontSize}},
+		{"emphasis", func() *Document {
+			text, styles := ParseMarkdownEmphasis("Some **bold** and *italic* and `code` and ***both*** of them.")
+			doc := NewDocument("emphasis", text, 320-pageMarginRight/2, charWidth, nil)
+			doc.Styles = styles
+			return doc
+		}, PageOptions{Width: 320, Height: 120, DPI: 72, FontSize: defaultFontSize}},
+		{"scrolled", func() *Document {
+			return NewDocument("scrolled", goldenText, 320-pageMarginRight/2, charWidth, nil)
+		}, PageOptions{Width: 320, Height: 120, DPI: 72, FontSize: defaultFontSize, StartIndex: 2}},
+		{"hidpi", func() *Document {
+			return NewDocument("hidpi", goldenText, 640-pageMarginRight, 2*charWidth, nil)
+		}, PageOptions{Width: 640, Height: 240, DPI: 144, FontSize: defaultFontSize}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RenderPage(tt.doc(), fam, tt.opts)
+			path := filepath.Join("testdata", "golden", tt.name+".png")
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := WritePNG(path, got); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("%v, go test -run TestRenderGolden -update writes it", err)
+			}
+			want, err := decodePNG(f)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the word rects aren't opaque, a png can't hold every
+			// color they have exactly so we compare what it can hold
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, got); err != nil {
+				t.Fatal(err)
+			}
+			if got, err = decodePNG(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("got a %v page, want %v", got.Bounds(), want.Bounds())
+			}
+			n := diffPixels(got, want)
+			if max := int(float64(got.Rect.Dx()*got.Rect.Dy()) * goldenMaxDiff / 100); n > max {
+				out := filepath.Join(os.TempDir(), "golden-"+tt.name+".png")
+				WritePNG(out, got)
+				t.Errorf("%d pixels are different, more than %d, the page is in %s", n, max, out)
+			}
+		})
+	}
+}
+
+func TestRenderPageNotBlank(t *testing.T) {
+	doc := NewDocument("x", goldenText, 200, charWidth, nil)
+	img := RenderPage(doc, testFamily(t), PageOptions{Width: 320, Height: 100, DPI: 72, FontSize: defaultFontSize})
+	dark := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i] < 128 {
+			dark++
+		}
+	}
+	if dark == 0 {
+		t.Errorf("nothing was drawn")
+	}
+}
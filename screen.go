@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Screen is the window side of the reader, it draws an App and keeps
+// whatever textures that needs
+type Screen struct {
+	window   *sdl.Window
+	renderer *sdl.Renderer
+
+	// bg of the App ends up in tex, it's as big as bg
+	tex  *sdl.Texture
+	rect sdl.Rect
+
+	scale DisplayScale // the one the overlays were made for
+
+	bookmarkPanel    *Overlay
+	promptBar        *Overlay
+	concordancePanel *Overlay
+	helpPanel        *Overlay
+	hud              *HUD
+
+	uploadTime time.Duration // what Render took to upload bg and the atlas, for the HUD
+}
+
+func NewScreen(window *sdl.Window, renderer *sdl.Renderer, app *App) (*Screen, error) {
+	s := &Screen{window: window, renderer: renderer, scale: app.scale}
+	if err := s.createTexture(app); err != nil {
+		return nil, err
+	}
+
+	var err error
+	font, size := app.fam.Regular, app.overlayFontSize()
+	if s.bookmarkPanel, err = NewOverlay(renderer, font, app.bookmarkRect(), size); err != nil {
+		s.Destroy()
+		return nil, err
+	}
+	if s.promptBar, err = NewOverlay(renderer, font, app.promptRect(), size); err != nil {
+		s.Destroy()
+		return nil, err
+	}
+	if s.concordancePanel, err = NewOverlay(renderer, font, app.concordanceRect(), size); err != nil {
+		s.Destroy()
+		return nil, err
+	}
+	if s.helpPanel, err = NewOverlay(renderer, font, app.helpRect(), size); err != nil {
+		s.Destroy()
+		return nil, err
+	}
+	if s.hud, err = NewHUD(renderer, font, app.hudRect(), size); err != nil {
+		s.Destroy()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Screen) createTexture(app *App) error {
+	w, h := app.size()
+	tex, err := s.renderer.CreateTexture(uint32(sdl.PIXELFORMAT_RGBA32), sdl.TEXTUREACCESS_STREAMING, w, h)
+	if err != nil {
+		return err
+	}
+	tex.SetBlendMode(sdl.BLENDMODE_BLEND)
+	if s.tex != nil {
+		s.tex.Destroy()
+	}
+	s.tex = tex
+	s.rect = sdl.Rect{X: 0, Y: 0, W: w, H: h}
+	return nil
+}
+
+// Resize is for window events, the window changed size (or moved to
+// another display) and the app has to know
+func (s *Screen) Resize(app *App) {
+	w, h, err := s.renderer.GetOutputSize()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	app.Resize(int(w), int(h), NewDisplayScale(s.window, s.renderer))
+}
+
+// resizeOverlays gives every overlay the rect and the font size it has
+// now, the window got bigger or it moved to a display with another scale
+func (s *Screen) resizeOverlays(app *App) {
+	s.scale = app.scale
+	size := app.overlayFontSize()
+	overlays := []struct {
+		o    *Overlay
+		rect sdl.Rect
+	}{
+		{s.bookmarkPanel, app.bookmarkRect()},
+		{s.promptBar, app.promptRect()},
+		{s.concordancePanel, app.concordanceRect()},
+		{s.helpPanel, app.helpRect()},
+		{s.hud.Overlay, app.hudRect()},
+	}
+	for _, ov := range overlays {
+		if err := ov.o.Resize(s.renderer, ov.rect, size); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// Render draws app, the frame isn't presented so that the caller can
+// draw on top of it
+func (s *Screen) Render(app *App) {
+	renderer := s.renderer
+
+	// bg was made again, so is everything as big as it
+	if w, h := app.size(); w != s.rect.W || h != s.rect.H {
+		if err := s.createTexture(app); err != nil {
+			fmt.Println(err)
+			return
+		}
+		s.resizeOverlays(app)
+	} else if app.scale != s.scale {
+		s.resizeOverlays(app)
+	}
+
+	renderer.SetDrawColor(255, 255, 255, 255)
+	renderer.Clear()
+
+	uploadStart := time.Now()
+	if err := app.damage.Upload(s.tex, app.bg); err != nil {
+		fmt.Println(err)
+	}
+	if err := app.atlas.Upload(); err != nil {
+		fmt.Println(err)
+	}
+	s.uploadTime = time.Since(uploadStart)
+
+	// scrolling within a line only moves what's already drawn up
+	offset := int32(app.scrollOffset)
+	src := sdl.Rect{X: 0, Y: offset, W: s.rect.W, H: s.rect.H - offset}
+	dst := sdl.Rect{X: 0, Y: 0, W: s.rect.W, H: s.rect.H - offset}
+	renderer.Copy(s.tex, &src, &dst)
+	if err := app.atlas.Draw(renderer, app.scrollOffset); err != nil {
+		fmt.Println(err)
+	}
+
+	// the lines we're selecting
+	if app.dragged {
+		for i := int32(0); i < app.selectedLine; i++ {
+			if app.hiRects.IsShown(int(i)) {
+				draw_rect_without_border(renderer, &app.hiRects.rects[i], &sdl.Color{R: 200, G: 100, B: 80, A: 100})
+			}
+		}
+	}
+
+	if len(app.search.Matches) > 0 {
+		rects, current := app.search.VisibleRects(app.doc, app.startIndex, app.numLines+1, app.fam,
+			PixelSize(app.ctx, app.fontSize), app.pageTop-app.scrollOffset, app.lineHeight())
+		if len(rects) > 0 {
+			draw_multiple_rects_without_border_filled(renderer, rects, &sdl.Color{R: 255, G: 200, B: 0, A: 100})
+		}
+		if current.W > 0 {
+			draw_rect_with_border(renderer, &current, &sdl.Color{R: 255, G: 100, B: 0, A: 255})
+		}
+	}
+
+	if rect, ok := app.cursorRect(); ok && app.vim {
+		draw_rect_with_border(renderer, &rect, &sdl.Color{R: 0, G: 0, B: 244, A: 255})
+	}
+
+	if app.showBookmarks {
+		if app.bookmarksChanged {
+			s.bookmarkPanel.DrawLines(BookmarkLabels(app.doc, app.bookmarks), app.bookmarkCursor)
+			app.bookmarksChanged = false
+		}
+		s.bookmarkPanel.Present(renderer)
+	}
+
+	if app.showConcordance {
+		if app.concordanceChanged {
+			s.concordancePanel.DrawLines(app.concordanceLines, -1)
+			app.concordanceChanged = false
+		}
+		s.concordancePanel.Present(renderer)
+	}
+
+	if app.showHelp {
+		if app.helpChanged {
+			s.helpPanel.DrawLines(app.helpLines(), -1)
+			app.helpChanged = false
+		}
+		s.helpPanel.Present(renderer)
+	}
+
+	if app.prompt.IsOpen() {
+		if app.promptChanged {
+			s.promptBar.DrawLines([]string{app.prompt.String()}, -1)
+			app.promptChanged = false
+		}
+		s.promptBar.Present(renderer)
+	}
+
+	if *showDamage {
+		app.damage.DrawDebug(renderer)
+	}
+}
+
+func (s *Screen) Destroy() {
+	for _, o := range []*Overlay{s.bookmarkPanel, s.promptBar, s.concordancePanel, s.helpPanel} {
+		if o != nil {
+			o.Destroy()
+		}
+	}
+	if s.hud != nil {
+		s.hud.Destroy()
+	}
+	if s.tex != nil {
+		s.tex.Destroy()
+	}
+}
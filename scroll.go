@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	scrollFriction = 0.88                   // what's left of the velocity after an update
+	scrollStop     = 0.02                   // lines per update, slower than that and we settle on a line
+	scrollDuration = 150 * time.Millisecond // how long a scroll with the keys takes
+	wheelVelocity  = 0.4                    // lines per update one notch of the wheel adds
+)
+
+// Scroller is where the page is scrolled to, in lines so that zooming
+// doesn't move it. The whole part is the line at the top of the page
+// (startIndex), the rest is how much of that line is scrolled off.
+//
+// The wheel flings the page (it keeps going and slows down), the keys
+// scroll by whole lines with an animation. Either way it ends up on a
+// whole line, everything that isn't drawing (selection, search) only
+// knows about whole lines.
+type Scroller struct {
+	Pos      float64
+	Velocity float64 // lines per update, see FixedStep
+	Max      float64 // the last line that can be at the top
+
+	Clock Clock // nil is the real one
+
+	anim *Tween
+}
+
+// SetLines sets Max so that the last page can't scroll past the end
+func (s *Scroller) SetLines(total, page int) {
+	s.Max = float64(total - page)
+	if s.Max < 0 {
+		s.Max = 0
+	}
+}
+
+func (s *Scroller) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+func (s *Scroller) clamp(pos float64) float64 {
+	return math.Max(0, math.Min(pos, s.Max))
+}
+
+// Fling adds to the velocity, negative goes up
+func (s *Scroller) Fling(v float64) {
+	s.anim = nil
+	s.Velocity += v
+}
+
+// ScrollTo animates to line pos
+func (s *Scroller) ScrollTo(pos float64) {
+	s.Velocity = 0
+	s.anim = NewTween(&s.Pos, s.clamp(pos), scrollDuration, EaseOutCubic, s.now())
+}
+
+// ScrollBy animates by lines, pressing a key again before the last
+// scroll is over goes on from where that one was going
+func (s *Scroller) ScrollBy(lines float64) {
+	s.ScrollTo(s.Target() + lines)
+}
+
+// Target is where the scroll animation ends, Pos when there's none
+func (s *Scroller) Target() float64 {
+	if s.anim != nil {
+		return s.anim.To
+	}
+	return s.Pos
+}
+
+// Jump goes to line pos right away
+func (s *Scroller) Jump(pos float64) {
+	s.anim = nil
+	s.Velocity = 0
+	s.Pos = s.clamp(pos)
+}
+
+// Step moves the page by one update, false when it isn't moving
+func (s *Scroller) Step() bool {
+	if s.anim != nil {
+		if !s.anim.Update(s.now()) {
+			s.anim = nil
+		}
+		return true
+	}
+	if s.Velocity == 0 {
+		if s.Pos != s.clamp(s.Pos) { // Max got smaller (the window got taller)
+			s.Pos = s.clamp(s.Pos)
+			return true
+		}
+		return false
+	}
+
+	s.Pos += s.Velocity
+	s.Velocity *= scrollFriction
+	if s.Pos <= 0 || s.Pos >= s.Max {
+		s.Pos = s.clamp(s.Pos)
+		s.Velocity = 0
+	}
+	if math.Abs(s.Velocity) < scrollStop {
+		// settle on the next line in the direction we were going, not
+		// back on the one we just left
+		line := math.Round(s.Pos)
+		if s.Velocity > 0 {
+			line = math.Ceil(s.Pos)
+		} else if s.Velocity < 0 {
+			line = math.Floor(s.Pos)
+		}
+		s.ScrollTo(line)
+	}
+	return true
+}
+
+// Moving is true while Step still has something to do
+func (s *Scroller) Moving() bool {
+	return s.anim != nil || s.Velocity != 0
+}
+
+// Line returns the line at the top and how many pixels of it are
+// scrolled off
+func (s *Scroller) Line(lineHeight int) (int, int) {
+	line := math.Floor(s.Pos)
+	return int(line), int(math.Round((s.Pos - line) * float64(lineHeight)))
+}
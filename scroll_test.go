@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const testFrame = 16 * time.Millisecond
+
+// steps s one frame at a time until it stops, or fails after frames frames
+func settle(t *testing.T, s *Scroller, clock *FakeClock, frames int) int {
+	for n := 0; n < frames; n++ {
+		clock.Advance(testFrame)
+		if !s.Step() {
+			return n
+		}
+	}
+	t.Fatalf("still moving after %d frames, pos %f velocity %f", frames, s.Pos, s.Velocity)
+	return frames
+}
+
+func TestScrollerClamp(t *testing.T) {
+	const msg = "ntest: %d, got: %f, want %f\n"
+	tests := []struct {
+		start float64
+		move  func(s *Scroller)
+		want  float64
+	}{
+		{5, func(s *Scroller) { s.ScrollBy(-20) }, 0},
+		{5, func(s *Scroller) { s.ScrollBy(200) }, 90},
+		{5, func(s *Scroller) { s.Fling(-10) }, 0},
+		{85, func(s *Scroller) { s.Fling(10) }, 90},
+		{5, func(s *Scroller) { s.Jump(-3) }, 0},
+		{5, func(s *Scroller) { s.Jump(1000) }, 90},
+	}
+	for i, tt := range tests {
+		clock := NewFakeClock()
+		s := Scroller{Clock: clock}
+		s.SetLines(100, 10)
+		s.Jump(tt.start)
+		tt.move(&s)
+		settle(t, &s, clock, 1000)
+		if s.Pos != tt.want {
+			t.Errorf(msg, i, s.Pos, tt.want)
+		}
+	}
+}
+
+func TestScrollerInertia(t *testing.T) {
+	clock := NewFakeClock()
+	s := Scroller{Clock: clock}
+	s.SetLines(1000, 10)
+	s.Fling(1)
+
+	s.Step()
+	first := s.Pos
+	s.Step()
+	if second := s.Pos - first; second >= first {
+		t.Errorf("didn't slow down, moved %f then %f", first, second)
+	}
+
+	settle(t, &s, clock, 1000)
+	if s.Pos <= 2 {
+		t.Errorf("stopped at %f, wanted it to keep going for a while", s.Pos)
+	}
+	if s.Pos != math.Round(s.Pos) {
+		t.Errorf("stopped at %f, between lines", s.Pos)
+	}
+}
+
+// a fling that runs out just past a line goes on to the next one and
+// doesn't come back
+func TestScrollerSettle(t *testing.T) {
+	const msg = "ntest: %d, got: %f, want %f\n"
+	tests := []struct {
+		pos, velocity float64
+		want          float64
+	}{
+		{5.3, 0.021, 6},
+		{5.7, -0.021, 5},
+		{5.9, 0.021, 6},
+		{5.1, -0.021, 5},
+	}
+	for i, tt := range tests {
+		clock := NewFakeClock()
+		s := Scroller{Clock: clock}
+		s.SetLines(100, 10)
+		s.Pos, s.Velocity = tt.pos, tt.velocity
+		settle(t, &s, clock, 1000)
+		if s.Pos != tt.want {
+			t.Errorf(msg, i, s.Pos, tt.want)
+		}
+	}
+}
+
+func TestScrollerScrollBy(t *testing.T) {
+	clock := NewFakeClock()
+	s := Scroller{Clock: clock}
+	s.SetLines(100, 10)
+
+	// pressing down three times in a row goes down three lines even
+	// though the first scroll isn't over yet
+	s.ScrollBy(1)
+	clock.Advance(testFrame)
+	s.Step()
+	s.ScrollBy(1)
+	clock.Advance(testFrame)
+	s.Step()
+	s.ScrollBy(1)
+
+	clock.Advance(scrollDuration)
+	s.Step()
+	if s.Pos != 3 {
+		t.Errorf("got: %f, want 3", s.Pos)
+	}
+	if s.Step() {
+		t.Errorf("still moving after %v", scrollDuration)
+	}
+}
+
+func TestScrollerLine(t *testing.T) {
+	const msg = "ntest: %d, got: %d %d, want %d %d\n"
+	tests := []struct {
+		pos        float64
+		lineHeight int
+		line, off  int
+	}{
+		{0, 20, 0, 0},
+		{3, 20, 3, 0},
+		{3.5, 20, 3, 10},
+		{3.26, 20, 3, 5},
+	}
+	for i, tt := range tests {
+		s := Scroller{Pos: tt.pos}
+		line, off := s.Line(tt.lineHeight)
+		if line != tt.line || off != tt.off {
+			t.Errorf(msg, i, line, off, tt.line, tt.off)
+		}
+	}
+}
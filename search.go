@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Start and End are byte offsets into doc.Text, a match can go on past
+// the end of a line. Line is the line it starts on.
+type SearchMatch struct {
+	Line  int
+	Start int
+	End   int
+}
+
+type SearchOptions struct {
+	IgnoreCase       bool
+	IgnoreDiacritics bool
+}
+
+type Search struct {
+	Query   string
+	Options SearchOptions
+	Matches []SearchMatch
+	Current int // index into Matches, -1 when there's nothing to show
+}
+
+func NewSearch() *Search {
+	return &Search{
+		Options: SearchOptions{IgnoreCase: true},
+		Current: -1,
+	}
+}
+
+// FoldForSearch returns s with case and/or diacritics removed, plus a table
+// that maps every byte of the folded string back to a byte in s (with one
+// extra entry for len(s)) so that matches can be drawn on the original line.
+func FoldForSearch(s string, opts SearchOptions) (string, []int) {
+	var folded strings.Builder
+	index := make([]int, 0, len(s)+1)
+
+	for i, r := range s {
+		decomposed := string(r)
+		if opts.IgnoreDiacritics {
+			decomposed = norm.NFD.String(decomposed)
+		}
+		for _, d := range decomposed {
+			if opts.IgnoreDiacritics && unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			if opts.IgnoreCase {
+				d = unicode.ToLower(d)
+			}
+			n, _ := folded.WriteRune(d)
+			for ; n > 0; n-- {
+				index = append(index, i)
+			}
+		}
+	}
+	index = append(index, len(s))
+
+	return folded.String(), index
+}
+
+// FindMatches looks for query in the whole text and not line by line, so
+// that a match doesn't depend on where the lines wrap
+func FindMatches(doc *Document, query string, opts SearchOptions) []SearchMatch {
+	if query == "" {
+		return nil
+	}
+	foldedQuery, _ := FoldForSearch(query, opts)
+	if foldedQuery == "" {
+		return nil
+	}
+
+	var result []SearchMatch
+	folded, index := FoldForSearch(doc.Text, opts)
+	for from := 0; from < len(folded); {
+		found := strings.Index(folded[from:], foldedQuery)
+		if found < 0 {
+			break
+		}
+		start := from + found
+		end := start + len(foldedQuery)
+		result = append(result, SearchMatch{Line: doc.LineAt(index[start]), Start: index[start], End: index[end]})
+
+		_, size := utf8.DecodeRuneInString(folded[start:])
+		from = start + size
+	}
+	return result
+}
+
+// Update reruns the query and makes the first match at or after line
+// the current one, this is what makes the search incremental.
+func (s *Search) Update(doc *Document, query string, line int) {
+	s.Query = query
+	s.Matches = FindMatches(doc, query, s.Options)
+	s.Current = -1
+	for i := range s.Matches {
+		if s.Matches[i].Line >= line {
+			s.Current = i
+			break
+		}
+	}
+	if s.Current < 0 && len(s.Matches) > 0 {
+		s.Current = 0 // wrap around
+	}
+}
+
+func (s *Search) Next() (SearchMatch, bool) {
+	if len(s.Matches) == 0 {
+		return SearchMatch{}, false
+	}
+	s.Current = (s.Current + 1) % len(s.Matches)
+	return s.Matches[s.Current], true
+}
+
+func (s *Search) Prev() (SearchMatch, bool) {
+	if len(s.Matches) == 0 {
+		return SearchMatch{}, false
+	}
+	s.Current -= 1
+	if s.Current < 0 {
+		s.Current = len(s.Matches) - 1
+	}
+	return s.Matches[s.Current], true
+}
+
+func (s *Search) Clear() {
+	s.Query = ""
+	s.Matches = nil
+	s.Current = -1
+}
+
+// what goes in front of the query in the search bar
+func (s *Search) Label() string {
+	label := "search"
+	if s.Options.IgnoreCase {
+		label += " [aA]"
+	}
+	if s.Options.IgnoreDiacritics {
+		label += " [a=ä]"
+	}
+	if len(s.Matches) > 0 {
+		label += fmt.Sprintf(" %d/%d", s.Current+1, len(s.Matches))
+	}
+	return label + ": "
+}
+
+// returns the rects of every match that's on the page, one for every
+// line a match is on, and the rect of the current match (its first line,
+// W == 0 when it's not on the page). top is the baseline of the first
+// line, the same one DrawToCtx gets.
+func (s *Search) VisibleRects(doc *Document, startIndex, numLines int,
+	fam *FontFamily, fontSize float64, top, lineHeight int) ([]sdl.Rect, sdl.Rect) {
+	var (
+		rects   []sdl.Rect
+		current sdl.Rect
+	)
+	for i, m := range s.Matches {
+		last := doc.LineAt(m.End - 1)
+		for line := m.Line; line <= last; line++ {
+			if line < startIndex || line >= startIndex+numLines || line >= len(doc.Lines) {
+				continue
+			}
+			// the part of the match that's on this line
+			offset := doc.LineOffset(line)
+			start, end := m.Start-offset, m.End-offset
+			if start < 0 {
+				start = 0
+			}
+			if end > len(doc.Lines[line]) {
+				end = len(doc.Lines[line])
+			}
+			if start >= end {
+				continue
+			}
+			x0, x1, ok := doc.RangeX(line, start, end, fam, fontSize)
+			if !ok {
+				continue
+			}
+
+			baseline := top + (line-startIndex)*lineHeight
+			rect := sdl.Rect{
+				X: int32(x0),
+				Y: int32(baseline - lineHeight*4/5),
+				W: int32(x1 - x0),
+				H: int32(lineHeight),
+			}
+			rects = append(rects, rect)
+			if i == s.Current && current.W == 0 {
+				current = rect
+			}
+		}
+	}
+	return rects, current
+}
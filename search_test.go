@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindMatches(t *testing.T) {
+	lines := []string{
+		"Le café est fermé.",
+		"CAFE au lait, cafe noir",
+		"nothing here",
+	}
+	doc := NewDocument("test.txt", strings.Join(lines, "\n"), 1000, 8, nil)
+
+	type test struct {
+		query string
+		opts  SearchOptions
+		out   []string
+	}
+
+	tests := []test{
+		{query: "cafe", opts: SearchOptions{}, out: []string{"cafe"}},
+		{query: "cafe", opts: SearchOptions{IgnoreCase: true}, out: []string{"CAFE", "cafe"}},
+		{query: "cafe", opts: SearchOptions{IgnoreDiacritics: true}, out: []string{"café", "cafe"}},
+		{query: "cafe", opts: SearchOptions{IgnoreCase: true, IgnoreDiacritics: true}, out: []string{"café", "CAFE", "cafe"}},
+		{query: "FERME", opts: SearchOptions{IgnoreCase: true, IgnoreDiacritics: true}, out: []string{"fermé"}},
+		{query: "é", opts: SearchOptions{}, out: []string{"é", "é"}},
+		{query: "", opts: SearchOptions{}, out: nil},
+	}
+
+	for ntest, tt := range tests {
+		matches := FindMatches(doc, tt.query, tt.opts)
+		if len(matches) != len(tt.out) {
+			const msg = "ntest: %d, got %d matches, want %d (%v)\n"
+			t.Errorf(msg, ntest, len(matches), len(tt.out), matches)
+			continue
+		}
+		for i, m := range matches {
+			got := doc.Text[m.Start:m.End]
+			if got != tt.out[i] {
+				const msg = "ntest: %d, match %d: got %q, want %q\n"
+				t.Errorf(msg, ntest, i, got, tt.out[i])
+			}
+		}
+	}
+}
+
+func TestSearchNextPrevWrap(t *testing.T) {
+	doc := NewDocument("test.txt", "a\nb\na\na", 1000, 8, nil)
+	search := NewSearch()
+	search.Update(doc, "a", 1)
+
+	if search.Current != 1 || search.Matches[search.Current].Line != 2 {
+		t.Fatalf("first match after line 1 should be on line 2, got %+v", search.Matches[search.Current])
+	}
+	if m, _ := search.Next(); m.Line != 3 {
+		t.Errorf("next: got line %d, want 3", m.Line)
+	}
+	if m, _ := search.Next(); m.Line != 0 {
+		t.Errorf("next should wrap around: got line %d, want 0", m.Line)
+	}
+	if m, _ := search.Prev(); m.Line != 3 {
+		t.Errorf("prev should wrap around: got line %d, want 3", m.Line)
+	}
+}
+
+// the rects go with the line height, not with the 20 the old font size had
+func TestSearchVisibleRects(t *testing.T) {
+	const msg = "ntest: %d, got: %v, want %v\n"
+	doc := NewDocument("test.txt", "b\na b", 1000, 8, SpaceSegmenter{})
+	fam := testFamily(t)
+	search := NewSearch()
+	search.Update(doc, "a", 0)
+
+	for i, lineHeight := range []int{18, 36, 50} {
+		rects, current := search.VisibleRects(doc, 0, 2, fam, 16, 30, lineHeight)
+		if len(rects) != 1 || rects[0] != current {
+			t.Fatalf("got: %v %v, want the one match\n", rects, current)
+		}
+		top := 30 + lineHeight - lineHeight*4/5
+		if got := rects[0]; int(got.Y) != top || int(got.H) != lineHeight {
+			t.Errorf(msg, i, got, []int{top, lineHeight})
+		}
+	}
+}
+
+// a wrap in the middle of the query doesn't lose the match, it gets a
+// rect on both lines
+func TestSearchAcrossWrap(t *testing.T) {
+	doc := NewDocument("test.txt", "said Harry Potter again", 12, 1, nil)
+	if len(doc.Lines) < 2 || !strings.HasSuffix(doc.Lines[0], "Harry ") {
+		t.Fatalf("the test wants Harry at the end of the first line, got %q\n", doc.Lines)
+	}
+	fam := testFamily(t)
+	search := NewSearch()
+	search.Update(doc, "harry potter", 0)
+
+	if len(search.Matches) != 1 {
+		t.Fatalf("got: %v, want one match\n", search.Matches)
+	}
+	m := search.Matches[0]
+	if m.Line != 0 || doc.Text[m.Start:m.End] != "Harry Potter" {
+		t.Errorf("got: %+v %q, want line 0 and Harry Potter\n", m, doc.Text[m.Start:m.End])
+	}
+
+	rects, current := search.VisibleRects(doc, 0, len(doc.Lines), fam, 16, 30, 20)
+	if len(rects) != 2 {
+		t.Fatalf("got: %v, want a rect on each line\n", rects)
+	}
+	if current != rects[0] || rects[0].Y == rects[1].Y {
+		t.Errorf("got: %v %v, want the current one on the first line\n", rects, current)
+	}
+	if int(rects[1].X) != doc.Left {
+		t.Errorf("got: %d, want the second rect at the start of the line %d\n", rects[1].X, doc.Left)
+	}
+
+	// only the second line on the page
+	rects, current = search.VisibleRects(doc, 1, 1, fam, 16, 30, 20)
+	if len(rects) != 1 || current != rects[0] {
+		t.Errorf("got: %v %v, want only the Potter half\n", rects, current)
+	}
+}
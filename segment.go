@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Segmenter splits a line into words. Spaces are never part of a word,
+// everything else (punctuation included) is, so that GetWord and friends
+// can trim it the same way for every language.
+type Segmenter interface {
+	Segment(line string) []Span
+}
+
+// SpaceSegmenter is what we did before we had segmenters,
+// a word is whatever is between two spaces
+type SpaceSegmenter struct{}
+
+func (SpaceSegmenter) Segment(line string) []Span {
+	var result []Span
+	start := -1
+	for i, r := range line {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				result = append(result, Span{Start: start, End: i})
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		result = append(result, Span{Start: start, End: len(line)})
+	}
+	return result
+}
+
+// DictSegmenter splits runs of scripts that don't put spaces between words
+// (chinese, japanese, thai...) with a longest match against a word list and
+// splits everything else on spaces. Without a word list every ideograph
+// ends up being its own word, which is still better than a whole line.
+type DictSegmenter struct {
+	words  map[string]bool
+	maxLen int // longest word in runes
+}
+
+func NewDictSegmenter(words []string) *DictSegmenter {
+	d := &DictSegmenter{words: make(map[string]bool)}
+	for _, w := range words {
+		d.words[w] = true
+		if n := utf8.RuneCountInString(w); n > d.maxLen {
+			d.maxLen = n
+		}
+	}
+	return d
+}
+
+// LoadDictSegmenter reads a word list with a word on every line, anything
+// after the first space or tab (frequencies, readings) is ignored.
+func LoadDictSegmenter(path string) (*DictSegmenter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		words = append(words, fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewDictSegmenter(words), nil
+}
+
+// scripts that are written without spaces between words
+func isDictScript(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana,
+		unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar) || r == 'ー'
+}
+
+// match returns the length in bytes of the longest word at the start
+// of s, or of the first character (with its combining marks) if no
+// word matches
+func (d *DictSegmenter) match(s string) int {
+	best := 0
+	end := 0
+	for n := 0; n < d.maxLen && end < len(s); n++ {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		if !isDictScript(r) && !unicode.Is(unicode.Mn, r) {
+			break
+		}
+		end += size
+		if d.words[s[:end]] {
+			best = end
+		}
+	}
+	if best > 0 {
+		return best
+	}
+
+	_, size := utf8.DecodeRuneInString(s)
+	for size < len(s) {
+		r, n := utf8.DecodeRuneInString(s[size:])
+		if !unicode.Is(unicode.Mn, r) {
+			break
+		}
+		size += n
+	}
+	return size
+}
+
+func (d *DictSegmenter) Segment(line string) []Span {
+	var result []Span
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case isDictScript(r):
+			n := d.match(line[i:])
+			result = append(result, Span{Start: i, End: i + n})
+			i += n
+		default:
+			start := i
+			for i < len(line) {
+				r, size := utf8.DecodeRuneInString(line[i:])
+				if unicode.IsSpace(r) || isDictScript(r) {
+					break
+				}
+				i += size
+			}
+			result = append(result, Span{Start: start, End: i})
+		}
+	}
+	return result
+}
+
+// NewSegmenter loads <dir><lang>.txt as a word list if there is one
+func NewSegmenter(lang string, dir string) (Segmenter, error) {
+	if lang == "" {
+		return NewDictSegmenter(nil), nil
+	}
+	seg, err := LoadDictSegmenter(dir + lang + ".txt")
+	if os.IsNotExist(err) {
+		return NewDictSegmenter(nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return seg, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func spanWords(line string, spans []Span) string {
+	var words []string
+	for _, span := range spans {
+		words = append(words, line[span.Start:span.End])
+	}
+	return strings.Join(words, "|")
+}
+
+func TestSegmenters(t *testing.T) {
+	dict := NewDictSegmenter([]string{"我们", "中国", "中国人", "喜欢", "สวัสดี", "ครับ"})
+
+	type test struct {
+		seg Segmenter
+		in  string
+		out string
+	}
+
+	tests := []test{
+		{seg: SpaceSegmenter{}, in: "  Hello,  world!\r", out: "Hello,|world!"},
+		{seg: SpaceSegmenter{}, in: "我们是中国人", out: "我们是中国人"},
+		{seg: dict, in: "我们是中国人。", out: "我们|是|中国人|。"},
+		{seg: dict, in: "「我们喜欢Go」", out: "「|我们|喜欢|Go」"},
+		{seg: dict, in: "สวัสดีครับ", out: "สวัสดี|ครับ"},
+		{seg: dict, in: "plain old text", out: "plain|old|text"},
+		{seg: NewDictSegmenter(nil), in: "日本語", out: "日|本|語"},
+	}
+
+	for ntest, tt := range tests {
+		result := spanWords(tt.in, tt.seg.Segment(tt.in))
+		if result != tt.out {
+			const msg = "ntest: %d, got: %q, want %q\n"
+			t.Errorf(msg, ntest, result, tt.out)
+		}
+	}
+}
+
+func TestLineBreaks(t *testing.T) {
+	thai := NewDictSegmenter([]string{"สวัสดี", "ครับ"})
+
+	type test struct {
+		in  string
+		seg Segmenter
+		out []int
+	}
+
+	tests := []test{
+		{in: "Hello world", out: []int{6}},
+		{in: "  indented", out: nil},
+		{in: "one, two", out: []int{5}},
+		{in: "well-known", out: []int{5}},
+		{in: "(quoted) $100 50%", out: []int{9, 14}},
+		{in: "he said \"hi\" !", out: []int{3, 8}},
+		{in: "你好世界", out: []int{3, 6, 9}},
+		{in: "「你好」。好", out: []int{6, 15}},
+		{in: "スーパー", out: []int{6}},
+		{in: "สวัสดีครับ", seg: thai, out: []int{18}},
+		{in: "สวัสดีครับ", out: nil},
+	}
+
+	for ntest, tt := range tests {
+		result := LineBreaks(tt.in, tt.seg)
+		if len(result) != len(tt.out) {
+			t.Errorf("ntest: %d, got: %v, want %v\n", ntest, result, tt.out)
+			continue
+		}
+		for i := range result {
+			if result[i] != tt.out[i] {
+				t.Errorf("ntest: %d, got: %v, want %v\n", ntest, result, tt.out)
+				break
+			}
+		}
+	}
+}
+
+func TestWrapLinesCJK(t *testing.T) {
+	// no spaces at all, this used to come back as one long line
+	input := strings.Repeat("我们是中国人。", 4)
+	lines := WrapLinesWith(input, 100, 10, nil) // 9 chars per line
+
+	if strings.Join(lines, "") != input {
+		t.Fatalf("lines don't add up to the input: %q", lines)
+	}
+	for i, line := range lines {
+		if n := len([]rune(line)); n > 9 {
+			t.Errorf("ntest: %d, line %q has %d chars", i, line, n)
+		}
+		if strings.HasPrefix(line, "。") {
+			t.Errorf("ntest: %d, line %q starts with a full stop", i, line)
+		}
+	}
+}
+
+func TestWrapLinesLongWord(t *testing.T) {
+	// a word longer than the line has to be cut somewhere
+	input := "a " + strings.Repeat("x", 20) + " b"
+	lines := WrapLines(input, 100, 10)
+
+	if strings.Join(lines, "") != input {
+		t.Fatalf("lines don't add up to the input: %q", lines)
+	}
+	for i, line := range lines {
+		if n := len(strings.TrimRight(line, " ")); n > 9 {
+			t.Errorf("ntest: %d, line %q is too long", i, line)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"sort"
+	"unicode"
+
+	"github.com/go-text/typesetting/di"
+	gotext "github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/language"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/math/fixed"
+)
+
+// ShapedGlyph is what the shaper turns a piece of text into. X is the pen
+// position from the start of the run, Start and End are the bytes of the
+// cluster the glyph belongs to (a ligature or a conjunct covers more than
+// one rune, a base and its marks share one).
+type ShapedGlyph struct {
+	Font    *Font // the one from the fallback chain that has the glyph
+	ID      gotext.GID
+	X       fixed.Int26_6
+	Advance fixed.Int26_6
+	XOffset fixed.Int26_6
+	YOffset fixed.Int26_6 // screen coordinates, y goes down
+	Start   int
+	End     int
+}
+
+// LineLayout is a line after bidi and shaping, the glyphs are in the
+// order they go on the screen with X from the start of the line
+type LineLayout struct {
+	Glyphs []ShapedGlyph
+	Width  fixed.Int26_6
+}
+
+type shapeItem struct {
+	start, end int // runes
+	script     language.Script
+	font       *Font
+}
+
+// harfbuzz wants a single script and a single font per call. Common
+// characters (spaces, punctuation, digits) and marks go with whatever
+// is around them as long as that font has them.
+func (f *Font) shapeItems(runes []rune) []shapeItem {
+	var result []shapeItem
+	for i, r := range runes {
+		script := language.LookupScript(r)
+		font := f.FontFor(r)
+		if len(result) == 0 {
+			result = append(result, shapeItem{start: i, end: i + 1, script: script, font: font})
+			continue
+		}
+		last := &result[len(result)-1]
+		common := script == language.Common || script == language.Inherited
+		if unicode.In(r, unicode.Mn, unicode.Me) || (common && last.font.HasGlyph(r)) {
+			font = last.font
+		}
+		switch {
+		case font != last.font:
+			result = append(result, shapeItem{start: i, end: i + 1, script: script, font: font})
+		case script == last.script, common:
+			last.end = i + 1
+		case last.script == language.Common:
+			last.end = i + 1
+			last.script = script
+		default:
+			result = append(result, shapeItem{start: i, end: i + 1, script: script, font: font})
+		}
+	}
+	return result
+}
+
+// Shape shapes text as a single run going in one direction, the glyphs
+// come back left to right. Runes f doesn't have are shaped with the
+// first of f.Fallbacks that does. The result comes from shapeCache and is
+// shared, don't change it.
+func (f *Font) Shape(text string, size float64, rtl bool) []ShapedGlyph {
+	key := shapeKey{font: f, size: fixed.Int26_6(size * 64), rtl: rtl, text: text}
+	if glyphs, ok := shapeCache.Get(key); ok {
+		return glyphs
+	}
+	glyphs := f.shape(text, size, rtl)
+	shapeCache.Put(key, glyphs)
+	return glyphs
+}
+
+func (f *Font) shape(text string, size float64, rtl bool) []ShapedGlyph {
+	runes := []rune(text)
+	offsets := make([]int, 0, len(runes)+1)
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(text))
+
+	dir := di.DirectionLTR
+	items := f.shapeItems(runes)
+	if rtl {
+		dir = di.DirectionRTL
+		for a, b := 0, len(items)-1; a < b; a, b = a+1, b-1 {
+			items[a], items[b] = items[b], items[a]
+		}
+	}
+
+	var (
+		result []ShapedGlyph
+		x      fixed.Int26_6
+	)
+	for _, item := range items {
+		out := f.shaper.Shape(shaping.Input{
+			Text:      runes,
+			RunStart:  item.start,
+			RunEnd:    item.end,
+			Direction: dir,
+			Face:      item.font.face,
+			Size:      fixed.Int26_6(size * 64),
+			Script:    item.script,
+			Language:  language.DefaultLanguage(),
+		})
+		for _, g := range out.Glyphs {
+			end := g.ClusterIndex + g.RuneCount
+			if end <= g.ClusterIndex {
+				end = g.ClusterIndex + 1
+			}
+			if end > len(runes) {
+				end = len(runes)
+			}
+			result = append(result, ShapedGlyph{
+				Font:    item.font,
+				ID:      g.GlyphID,
+				X:       x,
+				Advance: g.XAdvance,
+				XOffset: g.XOffset,
+				YOffset: -g.YOffset,
+				Start:   offsets[g.ClusterIndex],
+				End:     offsets[end],
+			})
+			x += g.XAdvance
+		}
+	}
+	return result
+}
+
+// LayoutLine shapes every run of bl separately and puts them next to
+// each other in visual order
+func (f *Font) LayoutLine(line string, bl *BidiLine, size float64) *LineLayout {
+	return NewFontFamily(f).LayoutLine(line, bl, nil, size)
+}
+
+// same as Font.LayoutLine but runs are also cut where the style changes,
+// styles are relative to the start of line
+func (fam *FontFamily) LayoutLine(line string, bl *BidiLine, styles []StyleSpan, size float64) *LineLayout {
+	layout := &LineLayout{}
+	for _, run := range bl.Runs {
+		pieces := styleRuns(styles, run.Start, run.End)
+		if run.RTL {
+			for a, b := 0, len(pieces)-1; a < b; a, b = a+1, b-1 {
+				pieces[a], pieces[b] = pieces[b], pieces[a]
+			}
+		}
+		for _, piece := range pieces {
+			font := fam.Face(piece.Style)
+			for _, g := range font.Shape(line[piece.Start:piece.End], size, run.RTL) {
+				g.X += layout.Width
+				g.Start += piece.Start
+				g.End += piece.Start
+				layout.Glyphs = append(layout.Glyphs, g)
+			}
+			if n := len(layout.Glyphs); n > 0 {
+				last := layout.Glyphs[n-1]
+				layout.Width = last.X + last.Advance
+			}
+		}
+	}
+	return layout
+}
+
+// styleRuns cuts [start, end) wherever a span in styles starts or ends,
+// Style of every piece is what all the spans covering it add up to
+func styleRuns(styles []StyleSpan, start, end int) []StyleSpan {
+	cuts := []int{start, end}
+	for _, st := range styles {
+		if st.Start > start && st.Start < end {
+			cuts = append(cuts, st.Start)
+		}
+		if st.End > start && st.End < end {
+			cuts = append(cuts, st.End)
+		}
+	}
+	sort.Ints(cuts)
+
+	var result []StyleSpan
+	for i := 1; i < len(cuts); i++ {
+		if cuts[i] == cuts[i-1] {
+			continue
+		}
+		piece := StyleSpan{Start: cuts[i-1], End: cuts[i]}
+		for _, st := range styles {
+			if st.Start <= piece.Start && st.End >= piece.End {
+				piece.Style |= st.Style
+			}
+		}
+		// no point in shaping the same style twice in a row
+		if n := len(result); n > 0 && result[n-1].Style == piece.Style {
+			result[n-1].End = piece.End
+			continue
+		}
+		result = append(result, piece)
+	}
+	return result
+}
+
+// Ranges returns where the clusters that start in bytes [start, end) are,
+// as [x0, x1) pairs from the start of the line, left to right
+func (l *LineLayout) Ranges(start, end int) [][2]int {
+	var (
+		result [][2]int
+		in     bool
+	)
+	for _, g := range l.Glyphs {
+		inside := g.Start >= start && g.Start < end
+		x0, x1 := g.X.Round(), (g.X + g.Advance).Round()
+		switch {
+		case inside && !in:
+			result = append(result, [2]int{x0, x1})
+		case inside && x1 > result[len(result)-1][1]:
+			result[len(result)-1][1] = x1
+		}
+		in = inside
+	}
+	return result
+}
+
+// layoutText is LayoutLine for text that isn't part of a Document
+func layoutText(f *Font, size float64, text string) *LineLayout {
+	levels, rtl := bidiLevels(text)
+	return f.LayoutLine(text, NewBidiLine(text, levels, rtl), size)
+}
+
+// DrawGlyphs draws shaped glyphs, pt is the start of the baseline
+func DrawGlyphs(dst draw.Image, src image.Image, size float64, glyphs []ShapedGlyph, pt fixed.Point26_6) {
+	for _, g := range glyphs {
+		dot := fixed.Point26_6{X: pt.X + g.X + g.XOffset, Y: pt.Y + g.YOffset}
+		g.Font.drawGlyph(dst, src, g.ID, size, dot)
+	}
+}
+
+// DrawText is what we use instead of freetype.Context.DrawString
+func DrawText(dst draw.Image, src image.Image, f *Font, size float64, text string, pt fixed.Point26_6) {
+	DrawGlyphs(dst, src, size, layoutText(f, size, text).Glyphs, pt)
+}
+
+func shapedWidth(glyphs []ShapedGlyph) fixed.Int26_6 {
+	if len(glyphs) == 0 {
+		return 0
+	}
+	last := glyphs[len(glyphs)-1]
+	return last.X + last.Advance
+}
+
+func roundPx(v fixed.Int26_6) float64 {
+	return math.Round(float64(v) / 64)
+}
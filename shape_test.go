@@ -0,0 +1,170 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	gotext "github.com/go-text/typesetting/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+func testFont(t *testing.T) *Font {
+	t.Helper()
+	font, err := ParseFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+func TestShapeClusters(t *testing.T) {
+	font := testFont(t)
+
+	glyphs := font.Shape("abc", 18, false)
+	if len(glyphs) != 3 {
+		t.Fatalf("got %d glyphs, want 3", len(glyphs))
+	}
+	for i, g := range glyphs {
+		if g.Start != i || g.End != i+1 {
+			t.Errorf("ntest: %d, got cluster [%d, %d)", i, g.Start, g.End)
+		}
+		if i > 0 && g.X <= glyphs[i-1].X {
+			t.Errorf("ntest: %d, x %v doesn't move right", i, g.X)
+		}
+	}
+
+	// rtl runs come back left to right, so the last letter goes first
+	rtl := font.Shape("abc", 18, true)
+	if len(rtl) != 3 || rtl[0].Start != 2 || rtl[2].Start != 0 {
+		t.Errorf("got %+v", rtl)
+	}
+}
+
+func TestCharWidthsCombiningMark(t *testing.T) {
+	font := testFont(t)
+
+	// e + combining acute is one cluster, the mark doesn't get a width of its own
+	widths := CharWidths(font, 18, "éx")
+	if len(widths) != 4 {
+		t.Fatalf("got %d widths, want 4", len(widths))
+	}
+	if widths[0] == 0 || widths[1] != 0 || widths[3] == 0 {
+		t.Errorf("got %v", widths)
+	}
+
+	const msg = "ntest: %d, got: %d, want %d\n"
+	total := widths[0] + widths[1] + widths[2] + widths[3]
+	if got, want := int(total), int(WidthOfString(font, 18, "éx")); got != want {
+		t.Errorf(msg, 0, got, want)
+	}
+}
+
+func TestLayoutLineRanges(t *testing.T) {
+	font := testFont(t)
+
+	line := "hello עולם"
+	layout := layoutText(font, 18, line)
+
+	hello := layout.Ranges(0, len("hello"))
+	hebrew := layout.Ranges(len("hello "), len(line))
+	if len(hello) != 1 || len(hebrew) != 1 {
+		t.Fatalf("got %v and %v", hello, hebrew)
+	}
+	if hello[0][0] != 0 || hebrew[0][0] <= hello[0][1] {
+		t.Errorf("got %v and %v", hello, hebrew)
+	}
+	if hebrew[0][1] != layout.Width.Round() {
+		t.Errorf("hebrew ends at %d, line is %d wide", hebrew[0][1], layout.Width.Round())
+	}
+}
+
+func TestDrawTextClips(t *testing.T) {
+	font := testFont(t)
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	// sticks out on every side, this used to be able to write out of bounds
+	DrawText(img, image.Black, font, 30, "Wig", fixed.P(-5, 25))
+
+	dark := 0
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if c := img.RGBAAt(x, y); c != (color.RGBA{255, 255, 255, 255}) {
+				dark++
+			}
+		}
+	}
+	if dark == 0 {
+		t.Errorf("nothing was drawn")
+	}
+}
+
+// testdata/mkfonts makes these, see testdata/README
+func testScriptFont(t *testing.T, name string) *Font {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	font, err := ParseFont(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+// beh joins on both sides, it gets another glyph at the start, in the
+// middle and at the end of a word than on its own
+func TestShapeArabicForms(t *testing.T) {
+	const msg = "ntest: %d, got: %d, want %d\n"
+	font := testScriptFont(t, "arabic-test.ttf")
+	isolated := font.Shape("ب", 18, true)
+	if len(isolated) != 1 {
+		t.Fatalf("got %d glyphs, want 1", len(isolated))
+	}
+
+	// by the byte they start at, beh is 2 bytes
+	forms := make(map[int]gotext.GID)
+	for _, g := range font.Shape("ببب", 18, true) {
+		forms[g.Start] = g.ID
+	}
+	tests := []struct {
+		got, want gotext.GID
+	}{
+		{isolated[0].ID, 2},
+		{forms[0], 3}, // init
+		{forms[2], 4}, // medi
+		{forms[4], 5}, // fina
+	}
+	for i, test := range tests {
+		if test.got != test.want {
+			t.Errorf(msg, i, test.got, test.want)
+		}
+	}
+}
+
+// क्ष is ka, virama and ssa, the font has one glyph for all of it and
+// the cluster covers all three
+func TestShapeDevanagariConjunct(t *testing.T) {
+	font := testScriptFont(t, "devanagari-test.ttf")
+	text := "क्ष"
+	glyphs := font.Shape(text, 18, false)
+	if len(glyphs) != 1 {
+		t.Fatalf("got %d glyphs %+v, want the conjunct", len(glyphs), glyphs)
+	}
+	if g := glyphs[0]; g.ID != 5 || g.Start != 0 || g.End != len(text) {
+		t.Errorf("got glyph %d for [%d, %d), want 5 for [0, %d)", g.ID, g.Start, g.End, len(text))
+	}
+
+	// and in a line the word is one range
+	layout := layoutText(font, 18, "क "+text)
+	if r := layout.Ranges(len("क "), len("क "+text)); len(r) != 1 || r[0][1] != layout.Width.Round() {
+		t.Errorf("got %v", r)
+	}
+}
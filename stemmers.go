@@ -0,0 +1,497 @@
+package main
+
+import (
+	"strings"
+)
+
+// Stemmers don't give us real lemmas ("running" -> "run" but "ran" stays
+// "ran"), but they are good enough to put most inflected forms under one
+// key. Irregular forms are what the lemma tables in ./lemmas/ are for.
+
+// EnglishStemmer is Martin Porter's original algorithm, ported from
+// his reference implementation (https://tartarus.org/martin/PorterStemmer/)
+type EnglishStemmer struct{}
+
+func (EnglishStemmer) Lemma(word string) string {
+	word = strings.ToLower(word)
+	for _, r := range word {
+		if r < 'a' || r > 'z' {
+			return word
+		}
+	}
+	p := porter{b: []byte(word), k: len(word) - 1}
+	return p.stem()
+}
+
+type porter struct {
+	b []byte
+	k int // end of the word
+	j int // general offset, set by ends()
+}
+
+func (p *porter) cons(i int) bool {
+	switch p.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		if i == 0 {
+			return true
+		}
+		return !p.cons(i - 1)
+	}
+	return true
+}
+
+// m measures the number of consonant sequences between 0 and j
+func (p *porter) m() int {
+	n := 0
+	i := 0
+	for {
+		if i > p.j {
+			return n
+		}
+		if !p.cons(i) {
+			break
+		}
+		i++
+	}
+	i++
+	for {
+		for {
+			if i > p.j {
+				return n
+			}
+			if p.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+		n++
+		for {
+			if i > p.j {
+				return n
+			}
+			if !p.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+	}
+}
+
+func (p *porter) vowelInStem() bool {
+	for i := 0; i <= p.j; i++ {
+		if !p.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *porter) doublec(j int) bool {
+	if j < 1 || p.b[j] != p.b[j-1] {
+		return false
+	}
+	return p.cons(j)
+}
+
+// cvc is true if i-2,i-1,i is consonant-vowel-consonant and the
+// last consonant is not w, x or y
+func (p *porter) cvc(i int) bool {
+	if i < 2 || !p.cons(i) || p.cons(i-1) || !p.cons(i-2) {
+		return false
+	}
+	switch p.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func (p *porter) ends(s string) bool {
+	length := len(s)
+	if length > p.k+1 {
+		return false
+	}
+	if string(p.b[p.k-length+1:p.k+1]) != s {
+		return false
+	}
+	p.j = p.k - length
+	return true
+}
+
+func (p *porter) setto(s string) {
+	p.b = append(p.b[:p.j+1], s...)
+	p.k = p.j + len(s)
+}
+
+func (p *porter) r(s string) {
+	if p.m() > 0 {
+		p.setto(s)
+	}
+}
+
+// step1ab gets rid of plurals and -ed or -ing
+func (p *porter) step1ab() {
+	if p.b[p.k] == 's' {
+		if p.ends("sses") {
+			p.k -= 2
+		} else if p.ends("ies") {
+			p.setto("i")
+		} else if p.b[p.k-1] != 's' {
+			p.k--
+		}
+	}
+	if p.ends("eed") {
+		if p.m() > 0 {
+			p.k--
+		}
+	} else if (p.ends("ed") || p.ends("ing")) && p.vowelInStem() {
+		p.k = p.j
+		if p.ends("at") {
+			p.setto("ate")
+		} else if p.ends("bl") {
+			p.setto("ble")
+		} else if p.ends("iz") {
+			p.setto("ize")
+		} else if p.doublec(p.k) {
+			p.k--
+			switch p.b[p.k] {
+			case 'l', 's', 'z':
+				p.k++
+			}
+		} else if p.m() == 1 && p.cvc(p.k) {
+			p.setto("e")
+		}
+	}
+}
+
+func (p *porter) step1c() {
+	if p.ends("y") && p.vowelInStem() {
+		p.b[p.k] = 'i'
+	}
+}
+
+// a suffix and what to replace it with
+type suffixRule struct {
+	suffix string
+	repl   string
+}
+
+// the first rule whose suffix matches wins, even if r() doesn't replace it
+func (p *porter) applyRules(rules []suffixRule) {
+	for _, rule := range rules {
+		if p.ends(rule.suffix) {
+			p.r(rule.repl)
+			return
+		}
+	}
+}
+
+var porterStep2 = map[byte][]suffixRule{
+	'a': {{"ational", "ate"}, {"tional", "tion"}},
+	'c': {{"enci", "ence"}, {"anci", "ance"}},
+	'e': {{"izer", "ize"}},
+	'l': {{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}},
+	'o': {{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}},
+	's': {{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"}},
+	't': {{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}},
+	'g': {{"logi", "log"}},
+}
+
+var porterStep3 = map[byte][]suffixRule{
+	'e': {{"icate", "ic"}, {"ative", ""}, {"alize", "al"}},
+	'i': {{"iciti", "ic"}},
+	'l': {{"ical", "ic"}, {"ful", ""}},
+	's': {{"ness", ""}},
+}
+
+var porterStep4 = map[byte][]string{
+	'a': {"al"},
+	'c': {"ance", "ence"},
+	'e': {"er"},
+	'i': {"ic"},
+	'l': {"able", "ible"},
+	'n': {"ant", "ement", "ment", "ent"},
+	'o': {"ion", "ou"},
+	's': {"ism"},
+	't': {"ate", "iti"},
+	'u': {"ous"},
+	'v': {"ive"},
+	'z': {"ize"},
+}
+
+func (p *porter) step4() {
+	for _, suffix := range porterStep4[p.b[p.k-1]] {
+		if !p.ends(suffix) {
+			continue
+		}
+		if suffix == "ion" && (p.j < 0 || (p.b[p.j] != 's' && p.b[p.j] != 't')) {
+			continue
+		}
+		if p.m() > 1 {
+			p.k = p.j
+		}
+		return
+	}
+}
+
+// step5 removes a final -e and changes -ll to -l if m() > 1
+func (p *porter) step5() {
+	p.j = p.k
+	if p.b[p.k] == 'e' {
+		a := p.m()
+		if a > 1 || a == 1 && !p.cvc(p.k-1) {
+			p.k--
+		}
+	}
+	if p.b[p.k] == 'l' && p.doublec(p.k) && p.m() > 1 {
+		p.k--
+	}
+}
+
+func (p *porter) stem() string {
+	if p.k <= 1 {
+		return string(p.b)
+	}
+	p.step1ab()
+	if p.k > 0 {
+		p.step1c()
+		p.applyRules(porterStep2[p.b[p.k-1]])
+		p.applyRules(porterStep3[p.b[p.k]])
+		p.step4()
+		p.step5()
+	}
+	return string(p.b[:p.k+1])
+}
+
+// helpers shared by the snowball stemmers, they all work on []rune
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func hasSuffix(word []rune, suffix string) bool {
+	s := []rune(suffix)
+	if len(s) > len(word) {
+		return false
+	}
+	return string(word[len(word)-len(s):]) == suffix
+}
+
+// longestSuffix returns the longest of suffixes that word ends with,
+// this is what snowball's "among" does
+func longestSuffix(word []rune, suffixes []string) string {
+	best := ""
+	for _, s := range suffixes {
+		if len([]rune(s)) > len([]rune(best)) && hasSuffix(word, s) {
+			best = s
+		}
+	}
+	return best
+}
+
+// regionAfter returns the index after the first non-vowel that follows
+// a vowel, starting at from. That's R1 when from == 0 and R2 when from == R1.
+func regionAfter(word []rune, from int, isVowel func(rune) bool) int {
+	for i := from + 1; i < len(word); i++ {
+		if !isVowel(word[i]) && isVowel(word[i-1]) {
+			return i + 1
+		}
+	}
+	return len(word)
+}
+
+// RussianStemmer is the snowball russian stemmer
+// (https://snowballstem.org/algorithms/russian/stemmer.html)
+type RussianStemmer struct{}
+
+func russianVowel(r rune) bool {
+	return strings.ContainsRune("аеиоуыэюя", r)
+}
+
+var (
+	ruPerfectiveGerund1 = []string{"в", "вши", "вшись"}
+	ruPerfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+	ruAdjective         = []string{"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"}
+	ruParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	ruParticiple2 = []string{"ивш", "ывш", "ующ"}
+	ruReflexive   = []string{"ся", "сь"}
+	ruVerb1       = []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"}
+	ruVerb2       = []string{"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+		"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю"}
+	ruNoun = []string{"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+		"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я"}
+	ruDerivational = []string{"ост", "ость"}
+)
+
+// ruRemove looks for the longest of group1 and group2 inside of word[rv:],
+// group1 endings only count when they follow an а or я (which stays)
+func ruRemove(word []rune, rv int, group1, group2 []string) ([]rune, bool) {
+	region := word[rv:]
+	s1 := longestSuffix(region, group1)
+	s2 := longestSuffix(region, group2)
+
+	if s1 == "" && s2 == "" {
+		return word, false
+	}
+	if len([]rune(s2)) >= len([]rune(s1)) {
+		return word[:len(word)-len([]rune(s2))], true
+	}
+
+	cut := len(word) - len([]rune(s1))
+	if cut-1 < rv || (word[cut-1] != 'а' && word[cut-1] != 'я') {
+		return word, false
+	}
+	return word[:cut], true
+}
+
+func (RussianStemmer) Lemma(word string) string {
+	w := []rune(strings.ReplaceAll(strings.ToLower(word), "ё", "е"))
+
+	rv := len(w)
+	for i, r := range w {
+		if russianVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	r1 := regionAfter(w, 0, russianVowel)
+	r2 := regionAfter(w, r1, russianVowel)
+
+	// step 1
+	var ok bool
+	if w, ok = ruRemove(w, rv, ruPerfectiveGerund1, ruPerfectiveGerund2); !ok {
+		w, _ = ruRemove(w, rv, nil, ruReflexive)
+
+		if w, ok = ruRemove(w, rv, nil, ruAdjective); ok {
+			w, _ = ruRemove(w, rv, ruParticiple1, ruParticiple2)
+		} else if w, ok = ruRemove(w, rv, ruVerb1, ruVerb2); !ok {
+			w, _ = ruRemove(w, rv, nil, ruNoun)
+		}
+	}
+
+	// step 2
+	if len(w) > rv && w[len(w)-1] == 'и' {
+		w = w[:len(w)-1]
+	}
+
+	// step 3
+	if s := longestSuffix(w[minInt(rv, len(w)):], ruDerivational); s != "" && len(w)-len([]rune(s)) >= r2 {
+		w = w[:len(w)-len([]rune(s))]
+	}
+
+	// step 4
+	region := w[minInt(rv, len(w)):]
+	switch s := longestSuffix(region, []string{"ейш", "ейше", "н", "ь"}); s {
+	case "ейш", "ейше":
+		w = w[:len(w)-len([]rune(s))]
+		if hasSuffix(w[minInt(rv, len(w)):], "нн") {
+			w = w[:len(w)-1]
+		}
+	case "н":
+		if hasSuffix(region, "нн") {
+			w = w[:len(w)-1]
+		}
+	case "ь":
+		w = w[:len(w)-1]
+	}
+
+	return string(w)
+}
+
+// GermanStemmer is the snowball german stemmer
+// (https://snowballstem.org/algorithms/german/stemmer.html)
+type GermanStemmer struct{}
+
+func germanVowel(r rune) bool {
+	return strings.ContainsRune("aeiouyäöü", r)
+}
+
+func (GermanStemmer) Lemma(word string) string {
+	w := []rune(strings.ReplaceAll(strings.ToLower(word), "ß", "ss"))
+
+	// u and y between vowels are treated as consonants
+	for i := 1; i < len(w)-1; i++ {
+		if (w[i] == 'u' || w[i] == 'y') && germanVowel(w[i-1]) && germanVowel(w[i+1]) {
+			w[i] = w[i] - 'a' + 'A'
+		}
+	}
+
+	r1 := regionAfter(w, 0, germanVowel)
+	if r1 < 3 {
+		r1 = 3
+	}
+	r2 := regionAfter(w, r1, germanVowel)
+
+	cut := func(s string) int { return len(w) - len([]rune(s)) }
+
+	// step 1
+	switch s := longestSuffix(w, []string{"em", "ern", "er", "e", "en", "es", "s"}); s {
+	case "em", "ern", "er":
+		if cut(s) >= r1 {
+			w = w[:cut(s)]
+		}
+	case "e", "en", "es":
+		if cut(s) >= r1 {
+			w = w[:cut(s)]
+			if hasSuffix(w, "niss") {
+				w = w[:len(w)-1]
+			}
+		}
+	case "s":
+		if cut(s) >= r1 && cut(s) > 0 && strings.ContainsRune("bdfghklmnrt", w[cut(s)-1]) {
+			w = w[:cut(s)]
+		}
+	}
+
+	// step 2
+	switch s := longestSuffix(w, []string{"en", "er", "est", "st"}); s {
+	case "en", "er", "est":
+		if cut(s) >= r1 {
+			w = w[:cut(s)]
+		}
+	case "st":
+		if cut(s) >= r1 && cut(s) > 3 && strings.ContainsRune("bdfghklmnt", w[cut(s)-1]) {
+			w = w[:cut(s)]
+		}
+	}
+
+	// step 3
+	switch s := longestSuffix(w, []string{"end", "ung", "ig", "ik", "isch", "lich", "heit", "keit"}); s {
+	case "end", "ung":
+		if cut(s) >= r2 {
+			w = w[:cut(s)]
+			if hasSuffix(w, "ig") && !hasSuffix(w, "eig") && cut("ig") >= r2 {
+				w = w[:cut("ig")]
+			}
+		}
+	case "ig", "ik", "isch":
+		if cut(s) >= r2 && !hasSuffix(w[:cut(s)], "e") {
+			w = w[:cut(s)]
+		}
+	case "lich", "heit":
+		if cut(s) >= r2 {
+			w = w[:cut(s)]
+			if (hasSuffix(w, "er") || hasSuffix(w, "en")) && cut("er") >= r1 {
+				w = w[:cut("er")]
+			}
+		}
+	case "keit":
+		if cut(s) >= r2 {
+			w = w[:cut(s)]
+			if t := longestSuffix(w, []string{"lich", "ig"}); t != "" && cut(t) >= r2 {
+				w = w[:cut(t)]
+			}
+		}
+	}
+
+	result := strings.NewReplacer("U", "u", "Y", "y", "ä", "a", "ö", "o", "ü", "u").Replace(string(w))
+	return result
+}
@@ -0,0 +1,342 @@
+// mkfonts writes the two small fonts the shaping tests use, there's no
+// Arabic or Devanagari font small enough to keep in the repo. Every glyph
+// is a box, what matters is the GSUB table:
+//
+//	arabic-test.ttf      beh (U+0628) with init, medi and fina forms
+//	devanagari-test.ttf  ka, virama and ssa, with an akhn ligature for क्ष
+//
+// Run it from the root of the repo: go run ./testdata/mkfonts
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+)
+
+const unitsPerEm = 1000
+
+type glyph struct {
+	advance int
+	w, h    int // the box, 0 for an empty glyph
+}
+
+type font struct {
+	glyphs []glyph
+	cmap   map[rune]uint16
+	gsub   []byte
+}
+
+func main() {
+	fonts := map[string]font{
+		"testdata/arabic-test.ttf":     arabic(),
+		"testdata/devanagari-test.ttf": devanagari(),
+	}
+	for path, f := range fonts {
+		if err := os.WriteFile(path, f.build(), 0644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+}
+
+func arabic() font {
+	const (
+		beh = iota + 2
+		behInit
+		behMedi
+		behFina
+	)
+	return font{
+		glyphs: []glyph{
+			{500, 400, 600}, // .notdef
+			{250, 0, 0},     // space
+			{600, 500, 300}, // beh
+			{300, 250, 300}, // beh.init
+			{250, 200, 300}, // beh.medi
+			{550, 450, 300}, // beh.fina
+		},
+		cmap: map[rune]uint16{' ': 1, 0x0628: beh},
+		gsub: gsub([]string{"arab"}, []feature{
+			{"fina", singleSubst(beh, behFina)},
+			{"init", singleSubst(beh, behInit)},
+			{"medi", singleSubst(beh, behMedi)},
+		}),
+	}
+}
+
+func devanagari() font {
+	const (
+		ka = iota + 2
+		virama
+		ssa
+		kssa
+	)
+	return font{
+		glyphs: []glyph{
+			{500, 400, 600}, // .notdef
+			{250, 0, 0},     // space
+			{600, 500, 500}, // ka
+			{0, 100, 100},   // virama
+			{600, 500, 500}, // ssa
+			{900, 800, 500}, // k.ssa
+		},
+		cmap: map[rune]uint16{' ': 1, 0x0915: ka, 0x094D: virama, 0x0937: ssa},
+		gsub: gsub([]string{"dev2", "deva"}, []feature{
+			{"akhn", ligatureSubst(ka, []uint16{virama, ssa}, kssa)},
+		}),
+	}
+}
+
+func u16(b *bytes.Buffer, vs ...int) {
+	for _, v := range vs {
+		binary.Write(b, binary.BigEndian, uint16(v))
+	}
+}
+
+func u32(b *bytes.Buffer, vs ...int) {
+	for _, v := range vs {
+		binary.Write(b, binary.BigEndian, uint32(v))
+	}
+}
+
+type feature struct {
+	tag    string
+	lookup []byte
+}
+
+// gsub has every feature in the default language of every script, one
+// lookup for every feature
+func gsub(scripts []string, features []feature) []byte {
+	var scriptList, featureList, lookupList bytes.Buffer
+
+	// the scripts share one script table
+	u16(&scriptList, len(scripts))
+	for _, s := range scripts {
+		scriptList.WriteString(s)
+		u16(&scriptList, 2+6*len(scripts))
+	}
+	u16(&scriptList, 4, 0)                     // the default langsys right after this, no others
+	u16(&scriptList, 0, 0xFFFF, len(features)) // no required feature
+	for i := range features {
+		u16(&scriptList, i)
+	}
+
+	u16(&featureList, len(features))
+	for i, f := range features {
+		featureList.WriteString(f.tag)
+		u16(&featureList, 2+6*len(features)+6*i)
+	}
+	for i := range features {
+		u16(&featureList, 0, 1, i)
+	}
+
+	u16(&lookupList, len(features))
+	offset := 2 + 2*len(features)
+	for _, f := range features {
+		u16(&lookupList, offset)
+		offset += len(f.lookup)
+	}
+	for _, f := range features {
+		lookupList.Write(f.lookup)
+	}
+
+	var b bytes.Buffer
+	u32(&b, 0x00010000)
+	u16(&b, 10, 10+scriptList.Len(), 10+scriptList.Len()+featureList.Len())
+	b.Write(scriptList.Bytes())
+	b.Write(featureList.Bytes())
+	b.Write(lookupList.Bytes())
+	return b.Bytes()
+}
+
+// a lookup with one subtable
+func lookup(kind int, subtable []byte) []byte {
+	var b bytes.Buffer
+	u16(&b, kind, 0, 1, 8)
+	b.Write(subtable)
+	return b.Bytes()
+}
+
+func singleSubst(from, to uint16) []byte {
+	var b bytes.Buffer
+	u16(&b, 2, 8, 1, int(to)) // format 2, the coverage after this
+	u16(&b, 1, 1, int(from))
+	return lookup(1, b.Bytes())
+}
+
+func ligatureSubst(first uint16, rest []uint16, lig uint16) []byte {
+	var b bytes.Buffer
+	u16(&b, 1, 8, 1, 14) // format 1, coverage at 8, one ligature set at 14
+	u16(&b, 1, 1, int(first))
+	u16(&b, 1, 4) // the set, its ligature right after it
+	u16(&b, int(lig), len(rest)+1)
+	for _, g := range rest {
+		u16(&b, int(g))
+	}
+	return lookup(4, b.Bytes())
+}
+
+func (f font) build() []byte {
+	tables := map[string][]byte{
+		"cmap": f.cmapTable(),
+		"GSUB": f.gsub,
+		"OS/2": f.os2(),
+		"post": f.post(),
+		"name": f.name(),
+	}
+	tables["glyf"], tables["loca"] = f.glyf()
+	tables["head"] = f.head()
+	tables["hhea"] = f.hhea()
+	tables["hmtx"] = f.hmtx()
+	tables["maxp"] = f.maxp()
+	return sfnt(tables)
+}
+
+func (f font) head() []byte {
+	var b bytes.Buffer
+	u32(&b, 0x00010000, 0x00010000, 0, 0x5F0F3CF5)
+	u16(&b, 0, unitsPerEm)
+	u32(&b, 0, 0, 0, 0) // created, modified
+	u16(&b, 0, 0, 1000, 1000)
+	u16(&b, 0, 8, 2, 0, 0) // style, lowest ppem, direction hint, short loca, glyph data format
+	return b.Bytes()
+}
+
+func (f font) hhea() []byte {
+	var b bytes.Buffer
+	u32(&b, 0x00010000)
+	u16(&b, 800, 0x10000-200, 0, 1000, 0, 0, 1000, 1, 0, 0)
+	u16(&b, 0, 0, 0, 0, 0, len(f.glyphs))
+	return b.Bytes()
+}
+
+func (f font) hmtx() []byte {
+	var b bytes.Buffer
+	for _, g := range f.glyphs {
+		u16(&b, g.advance, 50)
+	}
+	return b.Bytes()
+}
+
+func (f font) maxp() []byte {
+	var b bytes.Buffer
+	u32(&b, 0x00010000)
+	u16(&b, len(f.glyphs), 4, 1, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0)
+	return b.Bytes()
+}
+
+func (f font) glyf() ([]byte, []byte) {
+	var glyf, loca bytes.Buffer
+	for _, g := range f.glyphs {
+		u16(&loca, glyf.Len()/2)
+		if g.w == 0 {
+			continue
+		}
+		x0, x1 := 50, 50+g.w
+		u16(&glyf, 1, x0, 0, x1, g.h)
+		u16(&glyf, 3, 0) // one contour of 4 points, no instructions
+		glyf.Write([]byte{1, 1, 1, 1})
+		u16(&glyf, x0, g.w, 0, -g.w)
+		u16(&glyf, 0, 0, g.h, 0)
+	}
+	u16(&loca, glyf.Len()/2)
+	return glyf.Bytes(), loca.Bytes()
+}
+
+// format 4 with a segment for every character
+func (f font) cmapTable() []byte {
+	runes := make([]int, 0, len(f.cmap))
+	for r := range f.cmap {
+		runes = append(runes, int(r))
+	}
+	sort.Ints(runes)
+	segs := len(runes) + 1
+
+	var sub bytes.Buffer
+	u16(&sub, segs*2, 0, 0, 0) // searchRange and the rest aren't needed to find anything
+	for _, r := range runes {
+		u16(&sub, r)
+	}
+	u16(&sub, 0xFFFF, 0)
+	for _, r := range runes {
+		u16(&sub, r)
+	}
+	u16(&sub, 0xFFFF)
+	for _, r := range runes {
+		u16(&sub, (int(f.cmap[rune(r)])-r)&0xFFFF)
+	}
+	u16(&sub, 1)
+	for i := 0; i < segs; i++ {
+		u16(&sub, 0)
+	}
+
+	var b bytes.Buffer
+	u16(&b, 0, 1, 3, 1)
+	u32(&b, 12)
+	u16(&b, 4, 6+sub.Len(), 0)
+	b.Write(sub.Bytes())
+	return b.Bytes()
+}
+
+func (f font) os2() []byte {
+	var b bytes.Buffer
+	u16(&b, 4, 500, 400, 5, 0)
+	u16(&b, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	b.Write(make([]byte, 10)) // panose
+	u32(&b, 0, 0, 0, 0)
+	b.WriteString("NONE")
+	u16(&b, 0x40, 0x20, 0xFFFF, 800, 0x10000-200, 0, 800, 200)
+	u32(&b, 0, 0)
+	u16(&b, 500, 700, 0, 0x20, 0)
+	return b.Bytes()
+}
+
+func (f font) post() []byte {
+	var b bytes.Buffer
+	u32(&b, 0x00030000, 0)
+	u16(&b, 0x10000-100, 50)
+	u32(&b, 0, 0, 0, 0, 0)
+	return b.Bytes()
+}
+
+func (f font) name() []byte {
+	var b bytes.Buffer
+	u16(&b, 0, 0, 6)
+	return b.Bytes()
+}
+
+func sfnt(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	var b bytes.Buffer
+	u32(&b, 0x00010000)
+	u16(&b, len(tags), 0, 0, 0)
+	offset := 12 + 16*len(tags)
+	for _, tag := range tags {
+		data := tables[tag]
+		b.WriteString(tag)
+		u32(&b, checksum(data), offset, len(data))
+		offset += (len(data) + 3) &^ 3
+	}
+	for _, tag := range tags {
+		b.Write(tables[tag])
+		b.Write(make([]byte, (4-len(tables[tag])%4)%4))
+	}
+	return b.Bytes()
+}
+
+func checksum(data []byte) int {
+	var sum uint32
+	padded := append(data, make([]byte, (4-len(data)%4)%4)...)
+	for i := 0; i < len(padded); i += 4 {
+		sum += binary.BigEndian.Uint32(padded[i:])
+	}
+	return int(sum)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Clock is where tweens get the time from, tests use a fake one so that
+// they don't depend on how fast the machine is
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// every easing takes the start value, the duration, the change and the
+// time since the start, in the same units as duration (Robert Penner's
+// argument order, it's what every easing library out there uses)
+type easingFunc func(b, d, c, t float64) float64
+
+func EaseLinear(b, d, c, t float64) float64 {
+	return c*t/d + b
+}
+
+func EaseInOutQuad(b, d, c, t float64) float64 {
+	t /= d / 2
+	if t < 1 {
+		return c/2*t*t + b
+	}
+	t--
+	return -c/2*(t*(t-2)-1) + b
+}
+
+func EaseInCubic(b, d, c, t float64) float64 {
+	t /= d
+	return c*t*t*t + b
+}
+
+func EaseOutCubic(b, d, c, t float64) float64 {
+	t = t/d - 1
+	return c*(t*t*t+1) + b
+}
+
+func EaseInOutCubic(b, d, c, t float64) float64 {
+	t /= d / 2
+	if t < 1 {
+		return c/2*t*t*t + b
+	}
+	t -= 2
+	return c/2*(t*t*t+2) + b
+}
+
+func EaseOutExpo(b, d, c, t float64) float64 {
+	if t >= d {
+		return b + c
+	}
+	return c*(1-math.Pow(2, -10*t/d)) + b
+}
+
+func EaseInOutExpo(b, d, c, t float64) float64 {
+	switch {
+	case t <= 0:
+		return b
+	case t >= d:
+		return b + c
+	}
+	t /= d / 2
+	if t < 1 {
+		return c/2*math.Pow(2, 10*(t-1)) + b
+	}
+	return c/2*(2-math.Pow(2, -10*(t-1))) + b
+}
+
+// EaseOutBack overshoots by about 10% and comes back
+func EaseOutBack(b, d, c, t float64) float64 {
+	const s = 1.70158
+	t = t/d - 1
+	return c*(t*t*((s+1)*t+s)+1) + b
+}
+
+// EaseOutElastic overshoots and wobbles a few times before it settles
+func EaseOutElastic(b, d, c, t float64) float64 {
+	switch {
+	case t <= 0:
+		return b
+	case t >= d:
+		return b + c
+	}
+	const p = 0.3 // period, as a part of d
+	t /= d
+	return c*math.Pow(2, -10*t)*math.Sin((t-p/4)*2*math.Pi/p) + c + b
+}
+
+// EaseSpring is a damped spring, it overshoots once a little and is
+// settled (within 0.1%) by the end
+func EaseSpring(b, d, c, t float64) float64 {
+	const (
+		zeta  = 0.5 // damping ratio, 1 wouldn't overshoot at all
+		omega = 14  // stiffness, e^(-zeta*omega) is about 0.001
+	)
+	if t >= d {
+		return b + c
+	}
+	t /= d
+	wd := omega * math.Sqrt(1-zeta*zeta)
+	x := math.Exp(-zeta*omega*t) * (math.Cos(wd*t) + zeta*omega/wd*math.Sin(wd*t))
+	return c*(1-x) + b
+}
+
+// Tween moves *v from From to To in Duration
+type Tween struct {
+	v        *float64
+	From, To float64
+	Start    time.Time
+	Duration time.Duration
+	Ease     easingFunc
+}
+
+func NewTween(v *float64, to float64, duration time.Duration, fn easingFunc, now time.Time) *Tween {
+	return &Tween{v: v, From: *v, To: to, Start: now, Duration: duration, Ease: fn}
+}
+
+// Update sets *v to where it is at now, false once it got to To
+func (tw *Tween) Update(now time.Time) bool {
+	elapsed := now.Sub(tw.Start)
+	if elapsed >= tw.Duration {
+		*tw.v = tw.To
+		return false
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	*tw.v = tw.Ease(tw.From, tw.Duration.Seconds(), tw.To-tw.From, elapsed.Seconds())
+	return true
+}
+
+// Animator runs all the tweens, one per value. Animating a value that's
+// already moving starts from wherever it is right now, so pressing zoom
+// twice zooms twice as far instead of the second press getting lost.
+type Animator struct {
+	clock  Clock
+	tweens []*Tween
+}
+
+func NewAnimator(clock Clock) *Animator {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &Animator{clock: clock}
+}
+
+func (a *Animator) find(v *float64) int {
+	for i, tw := range a.tweens {
+		if tw.v == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// To animates *v to "to", replacing whatever *v was doing before
+func (a *Animator) To(v *float64, to float64, duration time.Duration, fn easingFunc) *Tween {
+	tw := NewTween(v, to, duration, fn, a.clock.Now())
+	if i := a.find(v); i >= 0 {
+		a.tweens[i] = tw
+	} else {
+		a.tweens = append(a.tweens, tw)
+	}
+	return tw
+}
+
+// Cancel stops *v where it is
+func (a *Animator) Cancel(v *float64) {
+	if i := a.find(v); i >= 0 {
+		a.tweens = append(a.tweens[:i], a.tweens[i+1:]...)
+	}
+}
+
+// Target is where *v is going, or *v if it isn't moving
+func (a *Animator) Target(v *float64) float64 {
+	if i := a.find(v); i >= 0 {
+		return a.tweens[i].To
+	}
+	return *v
+}
+
+func (a *Animator) Animating(v *float64) bool {
+	return a.find(v) >= 0
+}
+
+// Running is true while anything is animating
+func (a *Animator) Running() bool {
+	return len(a.tweens) > 0
+}
+
+// Update moves every value to where it should be now, it's false when
+// nothing was animating (so nothing has to be redrawn). The frame a
+// tween finishes on still counts.
+func (a *Animator) Update() bool {
+	if len(a.tweens) == 0 {
+		return false
+	}
+	now := a.clock.Now()
+	running := a.tweens[:0]
+	for _, tw := range a.tweens {
+		if tw.Update(now) {
+			running = append(running, tw)
+		}
+	}
+	for i := len(running); i < len(a.tweens); i++ {
+		a.tweens[i] = nil
+	}
+	a.tweens = running
+	return true
+}
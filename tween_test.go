@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// FakeClock only moves when it's told to
+type FakeClock struct {
+	now time.Time
+}
+
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *FakeClock) Now() time.Time { return c.now }
+
+func (c *FakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+var testEasings = map[string]easingFunc{
+	"Linear":     EaseLinear,
+	"InOutQuad":  EaseInOutQuad,
+	"InCubic":    EaseInCubic,
+	"OutCubic":   EaseOutCubic,
+	"InOutCubic": EaseInOutCubic,
+	"OutExpo":    EaseOutExpo,
+	"InOutExpo":  EaseInOutExpo,
+	"OutBack":    EaseOutBack,
+	"OutElastic": EaseOutElastic,
+	"Spring":     EaseSpring,
+}
+
+func TestEasingEnds(t *testing.T) {
+	const (
+		b, c, d = 10.0, 5.0, 2.0
+		eps     = 0.01
+	)
+	for name, fn := range testEasings {
+		if got := fn(b, d, c, 0); math.Abs(got-b) > eps {
+			t.Errorf("%s: at the start got %f, want %f", name, got, b)
+		}
+		if got := fn(b, d, c, d); math.Abs(got-(b+c)) > eps {
+			t.Errorf("%s: at the end got %f, want %f", name, got, b+c)
+		}
+	}
+}
+
+func TestEasingHalfway(t *testing.T) {
+	const msg = "%s: got %f, want %f\n"
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{"Linear", 0.5},
+		{"InOutQuad", 0.5},
+		{"InCubic", 0.125},
+		{"OutCubic", 0.875},
+		{"InOutCubic", 0.5},
+		{"InOutExpo", 0.5},
+	}
+	for _, tt := range tests {
+		if got := testEasings[tt.name](0, 1, 1, 0.5); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf(msg, tt.name, got, tt.want)
+		}
+	}
+}
+
+// back, elastic and spring are supposed to go past the end and come back
+func TestEasingOvershoot(t *testing.T) {
+	for _, name := range []string{"OutBack", "OutElastic", "Spring"} {
+		max := 0.0
+		for i := 0; i <= 100; i++ {
+			max = math.Max(max, testEasings[name](0, 1, 1, float64(i)/100))
+		}
+		if max <= 1 {
+			t.Errorf("%s: never went past 1", name)
+		}
+	}
+}
+
+func TestTween(t *testing.T) {
+	clock := NewFakeClock()
+	v := 10.0
+	tw := NewTween(&v, 20, 100*time.Millisecond, EaseLinear, clock.Now())
+
+	clock.Advance(25 * time.Millisecond)
+	if !tw.Update(clock.Now()) || v != 12.5 {
+		t.Errorf("after 25ms got %f, want 12.5", v)
+	}
+	clock.Advance(time.Second) // a frame that took forever still ends on To
+	if tw.Update(clock.Now()) || v != 20 {
+		t.Errorf("after the end got %f, want 20", v)
+	}
+}
+
+func TestAnimatorRetarget(t *testing.T) {
+	clock := NewFakeClock()
+	a := NewAnimator(clock)
+	size := 18.0
+
+	// zoom twice quickly, the second one goes on from the first
+	a.To(&size, a.Target(&size)+2, 100*time.Millisecond, EaseLinear)
+	clock.Advance(50 * time.Millisecond)
+	a.Update()
+	if size != 19 {
+		t.Errorf("halfway got %f, want 19", size)
+	}
+	a.To(&size, a.Target(&size)+2, 100*time.Millisecond, EaseLinear)
+	if got := a.Target(&size); got != 22 {
+		t.Errorf("target got %f, want 22", got)
+	}
+	clock.Advance(50 * time.Millisecond)
+	a.Update()
+	if size != 20.5 { // from 19, halfway to 22
+		t.Errorf("after retargeting got %f, want 20.5", size)
+	}
+
+	clock.Advance(50 * time.Millisecond)
+	if !a.Update() || size != 22 {
+		t.Errorf("at the end got %f, want 22 and a redraw", size)
+	}
+	if a.Update() || a.Animating(&size) {
+		t.Errorf("still animating after the end")
+	}
+}
+
+func TestAnimatorCancel(t *testing.T) {
+	clock := NewFakeClock()
+	a := NewAnimator(clock)
+	x, y := 0.0, 0.0
+	a.To(&x, 10, 100*time.Millisecond, EaseLinear)
+	a.To(&y, 10, 100*time.Millisecond, EaseLinear)
+
+	clock.Advance(30 * time.Millisecond)
+	a.Update()
+	a.Cancel(&x)
+	clock.Advance(time.Second)
+	a.Update()
+	if math.Abs(x-3) > 1e-9 || y != 10 {
+		t.Errorf("got x %f y %f, want x 3 (cancelled) y 10", x, y)
+	}
+	if a.Target(&x) != x {
+		t.Errorf("target of a cancelled value should be where it is")
+	}
+}
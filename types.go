@@ -15,6 +15,31 @@ import (
 type WordRects struct {
 	Rect   image.Rectangle
 	LineNr int
+	Start  int // byte offsets of the word in Lines[LineNr]
+	End    int
+}
+
+// Span is a [Start, End) range of bytes in a line, see Segmenter
+type Span struct {
+	Start int
+	End   int
+}
+
+// FontStyle is a set of flags, a span can be bold and italic at once
+type FontStyle uint8
+
+const (
+	StyleBold FontStyle = 1 << iota
+	StyleItalic
+	StyleMono
+)
+
+// StyleSpan is a styled range of Document.Text, spans can overlap and
+// their styles add up
+type StyleSpan struct {
+	Start int
+	End   int
+	Style FontStyle
 }
 
 // DB Types
@@ -22,6 +47,55 @@ type WordRects struct {
 type DBEntry map[string]*DBVal
 
 type DBVal struct {
-	Value string
-	Tags  []string
+	Value  string
+	Tags   []string
+	Forms  []string // the surface forms we saw for this lemma
+	Proper bool     // looks like a name, see GetUniqueWords
 }
+
+// Document keeps the original text around so that we can store positions
+// as byte offsets into Text instead of line numbers. Line numbers change
+// every time we rewrap (font size, window width), offsets don't.
+type Document struct {
+	Name       string
+	Text       string
+	Lines      []string
+	Offsets    []int // Offsets[i] is where Lines[i] starts in Text
+	Highlights []Highlight
+	Segmenter  Segmenter   // how Lines are split into words
+	Width      int         // what Lines were wrapped to, RTL lines are right aligned to it
+	Left       int         // where lines start on the page in pixels, the left margin
+	Styles     []StyleSpan // bold, italic and code from the markup we imported
+
+	bidi         []*BidiLine   // see Document.Bidi
+	layouts      []*LineLayout // see Document.Layout
+	layoutFamily *FontFamily
+	layoutSize   float64
+}
+
+// Start and End are byte offsets into Document.Text, for a bookmark
+// that was set without a selection Start == End.
+type Bookmark struct {
+	Name  string
+	Start int
+	End   int
+}
+
+// Highlight is a saved range of text, unlike HiLineRects which
+// only lives until the mouse button goes up.
+type Highlight struct {
+	Start int
+	End   int
+	Color HighlightColor
+	Note  string
+}
+
+type HighlightColor int
+
+const (
+	HighlightYellow HighlightColor = iota
+	HighlightGreen
+	HighlightBlue
+	HighlightPink
+	numHighlightColors
+)
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/golang/freetype"
+	"github.com/veandco/go-sdl2/sdl"
+	"golang.org/x/image/math/fixed"
+)
+
+// Overlay is a small box of text (side panels, prompts) that gets drawn
+// on top of the page. It has its own image and texture so that we don't
+// have to touch the page texture when the overlay changes.
+type Overlay struct {
+	Rect     sdl.Rect
+	img      *image.RGBA
+	tex      *sdl.Texture
+	ctx      *freetype.Context
+	font     *Font
+	fg       image.Image
+	fontSize float64
+
+	lines    []string // what DrawLines got last, so that Resize can redraw it
+	selected int
+}
+
+const overlayPadding = 6
+
+func NewOverlay(renderer *sdl.Renderer, font *Font, rect sdl.Rect, fontSize float64) (*Overlay, error) {
+	tex, err := renderer.CreateTexture(uint32(sdl.PIXELFORMAT_RGBA32), sdl.TEXTUREACCESS_STREAMING, rect.W, rect.H)
+	if err != nil {
+		return nil, err
+	}
+	tex.SetBlendMode(sdl.BLENDMODE_BLEND)
+
+	img := image.NewRGBA(image.Rect(0, 0, int(rect.W), int(rect.H)))
+
+	ctx := freetype.NewContext()
+	ctx.SetFont(font.Font)
+	ctx.SetDPI(72)
+	ctx.SetFontSize(fontSize)
+	ctx.SetClip(img.Bounds())
+	ctx.SetDst(img)
+
+	fg := image.NewUniform(color.RGBA{0, 0, 0, 255})
+	ctx.SetSrc(fg)
+
+	return &Overlay{Rect: rect, img: img, tex: tex, ctx: ctx, font: font, fg: fg, fontSize: fontSize}, nil
+}
+
+func (o *Overlay) LineHeight() int {
+	return overlayLineHeight(o.fontSize)
+}
+
+// overlays are drawn at 72 DPI, a point is a pixel
+func overlayLineHeight(fontSize float64) int {
+	return fixed.Int26_6(fontSize * 1.2 * 64).Round()
+}
+
+// selected < 0 means nothing is selected
+func (o *Overlay) DrawLines(lines []string, selected int) {
+	o.lines, o.selected = lines, selected
+	draw.Draw(o.img, o.img.Bounds(), image.NewUniform(color.RGBA{240, 240, 240, 230}), image.Point{0, 0}, draw.Src)
+
+	lineHeight := o.LineHeight()
+	for i, line := range lines {
+		top := overlayPadding + i*lineHeight
+		if top > o.img.Bounds().Max.Y {
+			break
+		}
+		if i == selected {
+			row := image.Rect(0, top, o.img.Bounds().Max.X, top+lineHeight)
+			draw.Draw(o.img, row, image.NewUniform(color.RGBA{0, 0, 244, 108}), image.Point{0, 0}, draw.Src)
+		}
+		pt := freetype.Pt(overlayPadding, top+o.ctx.PointToFixed(o.fontSize).Round())
+		DrawText(o.img, o.fg, o.font, o.fontSize, line, pt)
+	}
+	o.tex.Update(nil, unsafe.Pointer(&o.img.Pix[0]), o.img.Stride)
+}
+
+// Resize moves the overlay to rect, the texture is only made again when
+// the size changed
+// Resize moves the overlay to rect and draws it at fontSize, on another
+// display both of them change
+func (o *Overlay) Resize(renderer *sdl.Renderer, rect sdl.Rect, fontSize float64) error {
+	if fontSize != o.fontSize {
+		o.fontSize = fontSize
+		o.ctx.SetFontSize(fontSize)
+		o.DrawLines(o.lines, o.selected)
+	}
+	if rect.W == o.Rect.W && rect.H == o.Rect.H {
+		o.Rect = rect
+		return nil
+	}
+	tex, err := renderer.CreateTexture(uint32(sdl.PIXELFORMAT_RGBA32), sdl.TEXTUREACCESS_STREAMING, rect.W, rect.H)
+	if err != nil {
+		return err
+	}
+	tex.SetBlendMode(sdl.BLENDMODE_BLEND)
+	o.tex.Destroy()
+	o.tex = tex
+	o.Rect = rect
+	o.img = image.NewRGBA(image.Rect(0, 0, int(rect.W), int(rect.H)))
+	o.ctx.SetClip(o.img.Bounds())
+	o.ctx.SetDst(o.img)
+	o.DrawLines(o.lines, o.selected)
+	return nil
+}
+
+func (o *Overlay) Present(renderer *sdl.Renderer) {
+	renderer.Copy(o.tex, nil, &o.Rect)
+}
+
+func (o *Overlay) Contains(x, y int32) bool {
+	return rectContains(o.Rect, x, y)
+}
+
+func rectContains(r sdl.Rect, x, y int32) bool {
+	return x >= r.X && x < r.X+r.W && y >= r.Y && y < r.Y+r.H
+}
+
+// returns the index of the line under y (window coordinates), or -1
+func (o *Overlay) LineAt(y int32) int {
+	return overlayLineAt(o.Rect, o.fontSize, y)
+}
+
+// the same as Overlay.LineAt for an overlay at rect, App knows where the
+// overlays are without having them
+func overlayLineAt(rect sdl.Rect, fontSize float64, y int32) int {
+	y -= rect.Y + overlayPadding
+	if y < 0 {
+		return -1
+	}
+	return int(y) / overlayLineHeight(fontSize)
+}
+
+func (o *Overlay) Destroy() {
+	o.tex.Destroy()
+}
+
+type PromptKind int
+
+const (
+	PromptNone PromptKind = iota
+	PromptBookmark
+	PromptNote
+	PromptSearch
+)
+
+// Prompt collects a line of text from sdl.TextInputEvent's, it's used
+// for naming bookmarks and the like. Kind tells us what to do with
+// the text once the user hits enter.
+type Prompt struct {
+	Kind  PromptKind
+	Label string
+	Text  string
+	open  bool
+}
+
+func (p *Prompt) Open(kind PromptKind, label string) {
+	p.Kind = kind
+	p.Label = label
+	p.Text = ""
+	p.open = true
+	sdl.StartTextInput()
+}
+
+func (p *Prompt) Close() {
+	p.Kind = PromptNone
+	p.open = false
+	sdl.StopTextInput()
+}
+
+func (p *Prompt) IsOpen() bool {
+	return p.open
+}
+
+func (p *Prompt) Insert(s string) {
+	p.Text += s
+}
+
+func (p *Prompt) Backspace() {
+	if p.Text == "" {
+		return
+	}
+	_, size := utf8.DecodeLastRuneInString(p.Text)
+	p.Text = p.Text[:len(p.Text)-size]
+}
+
+func (p *Prompt) String() string {
+	return p.Label + p.Text + "_"
+}
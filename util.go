@@ -1,46 +1,36 @@
 package main
 
 import (
-	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"math"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/golang/freetype"
-	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/cases"
 )
 
 func OmitTrailingPunctuation(str string) string {
 	if AllNonAlpha(str) {
 		return str
 	}
-
-	end := len(str) - 1
-	for IsAlpha(str[end]) == false {
-		end--
-	}
-	return str[:end+1]
+	return strings.TrimRightFunc(str, func(r rune) bool { return !IsAlphaRune(r) })
 }
 
 func OmitPrecedingPunctuation(str string) string {
 	if AllNonAlpha(str) {
 		return str
 	}
-
-	start := 0
-	for IsAlpha(str[start]) == false {
-		start++
-	}
-	return str[start:]
+	return strings.TrimLeftFunc(str, func(r rune) bool { return !IsAlphaRune(r) })
 }
 
 func HasNonAlpha(str string) bool {
-	for _, c := range []byte(str) {
-		if !IsAlpha(c) {
+	for _, r := range str {
+		if !IsAlphaRune(r) {
 			return true
 		}
 	}
@@ -48,8 +38,8 @@ func HasNonAlpha(str string) bool {
 }
 
 func AllNonAlpha(str string) bool {
-	for _, c := range []byte(str) {
-		if IsAlpha(c) {
+	for _, r := range str {
+		if IsAlphaRune(r) {
 			return false
 		}
 	}
@@ -57,8 +47,8 @@ func AllNonAlpha(str string) bool {
 }
 
 func HasCapitalLetter(str string) bool {
-	for _, c := range []byte(str) {
-		if IsCapital(c) {
+	for _, r := range str {
+		if unicode.IsUpper(r) {
 			return true
 		}
 	}
@@ -73,37 +63,135 @@ func IsAlpha(c byte) bool {
 	return (c >= byte('A')) && (c <= byte('z'))
 }
 
+// same as IsAlpha for ascii, any unicode letter otherwise
+func IsAlphaRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return IsAlpha(byte(r))
+	}
+	return unicode.IsLetter(r)
+}
+
+// FoldCase is what we do to a word before it becomes a key, it's unicode
+// case folding and not just ToLower so that "STRASSE" and "straße" or
+// "ΣΊΣΥΦΟΣ" and "σίσυφος" end up the same.
+func FoldCase(word string) string {
+	return cases.Fold().String(word)
+}
+
+func WordKey(lem Lemmatizer, word string) string {
+	return lem.Lemma(FoldCase(word))
+}
+
+// abbreviations that end with a dot but don't end the sentence
+var notSentenceEnd = map[string]bool{
+	"Mr.": true, "Mrs.": true, "Ms.": true, "Dr.": true, "St.": true, "Prof.": true,
+}
+
+func EndsSentence(word string) bool {
+	word = strings.TrimRight(word, "\"'”’»)]」』\n\r")
+	if notSentenceEnd[word] {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(word)
+	return r == '.' || r == '!' || r == '?' || r == '…' || r == '。' || r == '！' || r == '？'
+}
+
+// what we trim off both ends of a word before we store it
+const wordPunctuation = ",.\n\r\\/\"'-;%^$#*@(!?)_-+=:<>[]{}~|"
+
 // It is possible that in the future the behaviour of this function will have to change.
-func GetUniqueWords(s []string) DBEntry {
+// Entries are keyed by WordKey(lem, word), the words themselves end up in Forms.
+//
+// Words that are capitalized in the middle of a sentence and never show up
+// in lowercase are most likely names, those are marked Proper (Value "I")
+// so that they don't count as unknown words.
+func GetUniqueWords(s []string, lem Lemmatizer, seg Segmenter) DBEntry {
+	if lem == nil {
+		lem = IdentityLemmatizer{}
+	}
+	if seg == nil {
+		seg = SpaceSegmenter{}
+	}
 	mk := make(DBEntry)
+
+	seenLower := make(map[string]bool)
+	capsMidSentence := make(map[string]bool)
+	sentenceStart := true
+
 	for i := 0; i < len(s); i++ {
-		words := strings.Split(s[i], " ")
-		for _, w := range words {
+		for _, span := range seg.Segment(s[i]) {
+			w := s[i][span.Start:span.End]
+			atStart := sentenceStart
+			sentenceStart = EndsSentence(w)
+
+			var key, form string
 			if HasNonAlpha(w) {
-				trimmed := strings.Trim(w, ",.\n\r\\/\"'-;%^$#*@(!?)_-+=:<>[]{}~|")
-				if !AllNonAlpha(w) {
-					if _, ok := mk[trimmed]; !ok {
-						mk[trimmed] = &DBVal{
-							Value: "A",
-							Tags:  []string{"a", "b", "c"},
-						}
+				trimmed := strings.Trim(w, wordPunctuation)
+				if AllNonAlpha(w) {
+					// a dash or a lone quote doesn't start a new sentence
+					sentenceStart = sentenceStart || atStart
+					continue
+				}
+				key = WordKey(lem, trimmed)
+				if _, ok := mk[key]; !ok {
+					mk[key] = &DBVal{
+						Value: "A",
+						Tags:  []string{"a", "b", "c"},
 					}
 				}
+				form = trimmed
 			} else {
-				// we don't want to create a new entry for ""
-				if w != "" {
-					mk[w] = &DBVal{
-						Value: "B",
-						Tags:  []string{"d", "e", "f"},
-					}
+				key = WordKey(lem, w)
+				val := &DBVal{
+					Value: "B",
+					Tags:  []string{"d", "e", "f"},
 				}
+				if old, ok := mk[key]; ok {
+					val.Forms = old.Forms
+				}
+				mk[key] = val
+				form = w
+			}
+			mk[key].AddForm(form)
+
+			first, _ := utf8.DecodeRuneInString(OmitPrecedingPunctuation(form))
+			switch {
+			case unicode.IsLower(first):
+				seenLower[key] = true
+			case unicode.IsUpper(first) && !atStart && utf8.RuneCountInString(form) > 1:
+				capsMidSentence[key] = true
 			}
 		}
-		words = nil
+	}
+
+	for key := range capsMidSentence {
+		if !seenLower[key] {
+			mk[key].Value = "I"
+			mk[key].Proper = true
+		}
 	}
 	return mk
 }
 
+func CountProperNouns(mk DBEntry) int {
+	count := 0
+	for _, v := range mk {
+		if v.Proper {
+			count++
+		}
+	}
+	return count
+}
+
+func (v *DBVal) AddForm(form string) {
+	for _, f := range v.Forms {
+		if f == form {
+			return
+		}
+	}
+	v.Forms = append(v.Forms, form)
+}
+
 func CountSpacesBetweenWords(str string) int {
 	var (
 		index  = 0
@@ -132,65 +220,23 @@ func CountSpacesBetweenWords(str string) int {
 	return result
 }
 
-func EaseInOutQuad(b, d, c, t float64) float64 {
-	if ((t / d) / 2) < 1 {
-		return c/2*(t/d)*(t/d) + b
-	}
-	return -c/2*((t/d)*((t/d)-2)-1) + b
-}
-
-type easingFunc func(b, d, c, t float64) float64
-
-func EasingAnimate(v *float64, max float64, fn easingFunc, anim_t string) func() bool {
-	var (
-		duration float64 = 0.0
-		animIn   bool
-		animOut  bool
-	)
-
-	switch anim_t {
-	case "animIn":
-		animIn = true
-	case "animOut":
-		animOut = true
-	}
-
-	return func() bool {
-		if animIn {
-			*v = fn(*v, max, max, duration)
-			duration += 0.5
-			if *v >= max {
-				animIn = false
-				duration = 0.0
-				*v = math.Round(*v)
-			}
-			return true
-		}
-
-		if animOut {
-			*v -= fn(0, max, max, duration)
-			duration += 0.5
-			if *v <= max {
-				animOut = false
-				duration = 0.0
-				*v = math.Round(*v)
-			}
-			return true
-		}
-		return false
-	}
+func WrapLines(input string, length int, font_w int) []string {
+	return WrapLinesWith(input, length, font_w, nil)
 }
 
-func WrapLines(input string, length int, font_w int) []string {
+// WrapLinesWith only breaks lines where LineBreaks allows it, seg is
+// needed for scripts like thai that have no spaces between words
+func WrapLinesWith(input string, length int, font_w int, seg Segmenter) []string {
 	sizeInPx := int(math.RoundToEven(float64(length/font_w))) - 1
-	result := make([]string, getSliceCount(input, sizeInPx))
-	pos := 0
+	if sizeInPx < 1 {
+		sizeInPx = 1
+	}
+	var result []string
 	for _, split := range strings.Split(input, "\n") {
-		slice := getSlice(split, sizeInPx)
+		slice := getSlice(split, sizeInPx, seg)
 		for s, end := slice(); ; s, end = slice() {
 			if s != "" {
-				result[pos] = s
-				pos += 1
+				result = append(result, s)
 			}
 			if end { // move this end to for(...)?
 				break
@@ -198,92 +244,53 @@ func WrapLines(input string, length int, font_w int) []string {
 		}
 		slice = nil
 	}
-	if result[len(result)-1] == "" {
-		return result[:len(result)-1]
-	}
-	return result
-}
-
-func getSliceCount(input string, linelen int) int32 {
-	var result int32
-	for _, split := range strings.Split(input, "\n") {
-		slice := getSlice(split, linelen)
-		for _, end := slice(); ; _, end = slice() {
-			result += 1
-			if end {
-				break
-			}
-		}
-		slice = nil
-	}
 	return result
 }
 
-func getSlice(s string, end int) func() (string, bool) {
-	var (
-		start int
-		last  int
-	)
+func getSlice(s string, end int, seg Segmenter) func() (string, bool) {
+	breaks := LineBreaks(s, seg)
+	var start int
 	return func() (string, bool) {
-		t := s[:] // TODO: move it down to for i:=0 ....?
-		if last+end > len(s) {
-			end = len(s) - last
-		}
-		for i := 0; i < end; i++ {
-			_, size := utf8.DecodeRuneInString(t)
-			t = t[size:]
+		last := start
+		for i := 0; i < end && last < len(s); i++ {
+			_, size := utf8.DecodeRuneInString(s[last:])
 			last += size
 		}
-		t = "" // TODO: is it correct to set t to an empty string?
+		// trailing spaces are allowed to hang off the end of the line
+		for last < len(s) && s[last] == ' ' {
+			last++
+		}
 		if last >= len(s) {
 			result := s[start:]
+			start = len(s)
 			return result, true
 		}
-		if !utf8.ValidString(s[start:last]) {
-			_, size := utf8.DecodeLastRuneInString(s[start:last])
-			last = last - size
+		// no break opportunity means one very long word, we have to cut it
+		if brk := LastBreak(breaks, start, last); brk > 0 {
+			last = brk
 		}
-		last = strings.LastIndex(s[:last], " ") + 1 // +1 is to remove extra space
 		result := s[start:last]
 		start = last
 		return result, false
 	}
 }
 
-// stolen from golang's stdlib
-func WidthOfString(font *truetype.Font, size float64, s string) float64 {
-	scale := size / float64(font.FUnitsPerEm()) // scale converts truetype.FUnit to float64
-
-	width := 0
-	prev, hasPrev := truetype.Index(0), false
-	for _, rune := range s {
-		index := font.Index(rune)
-		if hasPrev {
-			width += int(font.Kern(fixed.Int26_6(font.FUnitsPerEm()), prev, index))
-		}
-		width += int(font.HMetric(fixed.Int26_6(font.FUnitsPerEm()), index).AdvanceWidth)
-		prev, hasPrev = index, true
-	}
-
-	return math.Round(float64(width) * scale)
+// both of these measure shaped glyphs, so ligatures, arabic contextual
+// forms and marks are measured the same way they are drawn
+func WidthOfString(font *Font, size float64, s string) float64 {
+	return roundPx(shapedWidth(font.Shape(s, size, isRTLParagraph(s))))
 }
 
-func CharWidths(font *truetype.Font, size float64, s string) []float64 {
-	scale := size / float64(font.FUnitsPerEm()) // scale converts truetype.FUnit to float64
-
+// widths[i] is the width of the cluster that starts at byte i, 0 for
+// every other byte
+func CharWidths(font *Font, size float64, s string) []float64 {
 	widths := make([]float64, len(s))
-	prev, hasPrev := truetype.Index(0), false
-	for i, rune := range s {
-		var tempVal int
-		index := font.Index(rune)
-		if hasPrev {
-			tempVal += int(font.Kern(fixed.Int26_6(font.FUnitsPerEm()), prev, index))
-		}
-		tempVal += int(font.HMetric(fixed.Int26_6(font.FUnitsPerEm()), index).AdvanceWidth)
-		prev, hasPrev = index, true
-		widths[i] = math.Round(float64(tempVal) * scale)
+	for _, g := range font.Shape(s, size, isRTLParagraph(s)) {
+		widths[g.Start] += float64(g.Advance) / 64
+	}
+	for i := range widths {
+		widths[i] = math.Round(widths[i])
 	}
-
 	return widths
 }
 
@@ -302,6 +309,19 @@ func GetSelectedCharLen(w int) int {
 	return index
 }
 
+// PageLines is how many lines fit in height pixels when the first
+// baseline is at top, the last line needs room for its descenders
+func PageLines(height, top, lineHeight int) int {
+	if lineHeight <= 0 {
+		return 1
+	}
+	n := (height-top-lineHeight/5)/lineHeight + 1
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
 // zero based indexing is important because this function is used
 // in mouse highlighting scenario, where it's being passed to []testTokens.
 func YCoordToNumLines(y int, lineHeight int) int {
@@ -316,90 +336,75 @@ func YCoordToNumLines(y int, lineHeight int) int {
 	return numLines
 }
 
-func DrawToCtx(bg *image.RGBA, ctx *freetype.Context, pt fixed.Point26_6,
-	tokens *[]string, font *truetype.Font,
+func DrawToCtx(bg *image.RGBA, ctx *freetype.Context, fg image.Image, pt fixed.Point26_6,
+	doc *Document, fam *FontFamily,
 	startIndex, numLines int,
 	fontSize float64, rects *[]WordRects) {
-	const windowOffset = 10
+	DrawPageRects(bg, ctx, pt, doc, fam, startIndex, numLines, fontSize, rects)
+
+	lineHeight := ctx.PointToFixed(fontSize)
+	size := PixelSize(ctx, fontSize)
+	for n := startIndex; n < numLines+startIndex && n < len(doc.Lines); n++ {
+		// RTL lines are drawn in visual order and right aligned
+		linePt := pt
+		linePt.X = fixed.I(doc.LineX(n, fam, size))
+		DrawGlyphs(bg, fg, size, doc.Layout(n, fam, size).Glyphs, linePt)
+		pt.Y += lineHeight
+	}
+}
 
+// DrawPageRects is DrawToCtx without the text, the highlights and the word
+// rects go into bg and the text is drawn on top of it from a GlyphAtlas.
+// fontSize is in points, ctx knows how big those are, see PixelSize.
+// Returns everything it drew, that's what has to be cleared for the next page.
+func DrawPageRects(bg *image.RGBA, ctx *freetype.Context, pt fixed.Point26_6,
+	doc *Document, fam *FontFamily,
+	startIndex, numLines int,
+	fontSize float64, rects *[]WordRects) []image.Rectangle {
+	var drawn []image.Rectangle
 	rectIndex := 0
-	lineIndex := 0
 
 	lineHeight := ctx.PointToFixed(fontSize)
-	spaceWidth := int(WidthOfString(font, fontSize, " "))
+	size := PixelSize(ctx, fontSize)
 	colorGreen := image.NewUniform(color.RGBA{0, 255, 0, 108})
 
 	// clear everything back to 0
 	for i := range *rects {
-		// maybe it would be beter to set .Rect through
-		// Min.X, Min.Y, Max.X, Max.Y instead of image.Rect(...)?
-		(*rects)[i].Rect = image.Rect(0, 0, 0, 0)
-		(*rects)[i].LineNr = 0
+		(*rects)[i] = WordRects{}
 	}
 
 	for n := startIndex; n < numLines+startIndex; n++ {
-		if n >= len(*tokens) {
+		if n >= len(doc.Lines) {
 			break
 		}
+		// highlights go underneath the text
+		drawn = append(drawn, DrawHighlights(bg, doc, n, pt, fam, size, lineHeight.Round())...)
 
-		_, err := ctx.DrawString((*tokens)[n], pt)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-
-		prevWidth := 0
-		currWidth := 0
-
-		var rect image.Rectangle
-
-		for i, word := range strings.Split((*tokens)[n], " ") {
-			if word == "\n" || word == "\r" {
-				break
+		for _, span := range doc.Words(n) {
+			// CJK lines have a lot more words than spaces
+			if rectIndex >= len(*rects) {
+				*rects = append(*rects, WordRects{})
 			}
+			x0, x1, ok := doc.RangeX(n, span.Start, span.End, fam, size)
+			if !ok {
+				continue
+			}
+			rect := image.Rect(x0, pt.Y.Round()+2, x1, pt.Y.Round()+5)
 
-			if i == 0 {
-				currWidth = windowOffset + int(WidthOfString(font, fontSize, word))
-
-				rect = image.Rect(windowOffset, pt.Y.Round()+2, currWidth, pt.Y.Round()+5)
-
-				(*rects)[rectIndex].Rect = rect
-				rectIndex += 1
-
-				draw.Draw(bg, rect, colorGreen, image.Point{0, 0}, draw.Src)
-
-				prevWidth += currWidth
-				currWidth = prevWidth + spaceWidth
-			} else {
-				rect = image.Rect(currWidth, pt.Y.Round()+2,
-					currWidth+int(WidthOfString(font, fontSize, word)), pt.Y.Round()+5)
-
-				(*rects)[rectIndex].Rect = rect
-				rectIndex += 1
-
-				draw.Draw(bg, rect, colorGreen, image.Point{0, 0}, draw.Src)
+			(*rects)[rectIndex] = WordRects{Rect: rect, LineNr: n, Start: span.Start, End: span.End}
+			rectIndex += 1
 
-				prevWidth = currWidth + int(WidthOfString(font, fontSize, word))
-				currWidth = prevWidth + spaceWidth
-			}
-			(*rects)[lineIndex].LineNr = n
-			lineIndex += 1
+			draw.Draw(bg, rect, colorGreen, image.Point{0, 0}, draw.Src)
+			drawn = append(drawn, rect)
 		}
 		pt.Y += lineHeight
 	}
+	return drawn
 }
 
 func GetWord(tokens *[]string, rects *[]WordRects, index int) string {
-	wordCount := 0
-	LineNr := (*rects)[index].LineNr
-	for i := index; (*rects)[i].LineNr == LineNr; i-- {
-		wordCount++
-		if i == 0 {
-			break
-		}
-	}
-	// TODO(optimize): such that we don't use strings.Split() anymore
-	desiredWord := strings.Split((*tokens)[LineNr], " ")[wordCount-1]
+	r := (*rects)[index]
+	desiredWord := (*tokens)[r.LineNr][r.Start:r.End]
 	noPrecedingPunc := OmitPrecedingPunctuation(desiredWord)
 
 	return OmitTrailingPunctuation(noPrecedingPunc)
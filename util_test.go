@@ -17,20 +17,22 @@ func TestGetUniqueWords(t *testing.T) {
 		{input: "free'd", output: []string{"free'd"}},
 		{input: "l'amour", output: []string{"l'amour"}},
 		{input: "one two", output: []string{"one", "two"}},
-		{input: "Mr Mrs", output: []string{"Mr", "Mrs"}},
-		{input: "Mr. Mrs.", output: []string{"Mr", "Mrs"}},
+		{input: "Mr Mrs", output: []string{"mr", "mrs"}},
+		{input: "Mr. Mrs.", output: []string{"mr", "mrs"}},
 		{input: "one!!!---", output: []string{"one"}},
 		{input: "..  one..", output: []string{"one"}},
 		{input: "forget-me-not", output: []string{"forget-me-not"}},
 		{input: "!  one--! two-", output: []string{"one", "two"}},
-		{input: "Abracadabra", output: []string{"Abracadabra"}},
+		{input: "Abracadabra", output: []string{"abracadabra"}},
+		{input: "The the THE", output: []string{"the"}},
+		{input: "Ёлка ёлка", output: []string{"ёлка"}},
 
 		{input: "one@(*#... two..@* three#^##@^", output: []string{"one", "two", "three"}},
 		{input: "one@(*#... two..@* three aga#^##@^", output: []string{"one", "two", "three", "aga"}},
 	}
 
 	for ntest, tt := range tests {
-		words := GetUniqueWords([]string{tt.input})
+		words := GetUniqueWords([]string{tt.input}, IdentityLemmatizer{}, nil)
 		if len(words) > len(tt.output) {
 			const msg = "%d: words len: %d, tt.output len: %d, input: %s, output: %s"
 			t.Errorf(msg, ntest, len(words), len(tt.output), tt.input, tt.output)
@@ -46,6 +48,62 @@ func TestGetUniqueWords(t *testing.T) {
 	}
 }
 
+func TestGetUniqueWordsLemmas(t *testing.T) {
+	words := GetUniqueWords([]string{"he runs, she ran", "running is fun"}, &LookupLemmatizer{
+		Table:    map[string]string{"ran": "run"},
+		Fallback: EnglishStemmer{},
+	}, nil)
+
+	run, ok := words["run"]
+	if !ok {
+		t.Fatalf("no entry for run in %v", words)
+	}
+	want := []string{"runs", "ran", "running"}
+	if strings.Join(run.Forms, " ") != strings.Join(want, " ") {
+		t.Errorf("got forms %v, want %v", run.Forms, want)
+	}
+	if _, ok := words["running"]; ok {
+		t.Errorf("running should be under run")
+	}
+}
+
+func TestGetUniqueWordsProperNouns(t *testing.T) {
+	text := []string{
+		"Yesterday Harry met Ron. The boy said",
+		"hi to Harry and the dog. I think Mr. Dursley",
+		"saw them. \"Paris\" is far, and so is paris.",
+	}
+	words := GetUniqueWords(text, IdentityLemmatizer{}, nil)
+
+	type test struct {
+		key    string
+		proper bool
+	}
+
+	tests := []test{
+		{key: "harry", proper: true},
+		{key: "ron", proper: true},
+		{key: "dursley", proper: true}, // Mr. doesn't end the sentence
+		{key: "yesterday", proper: false},
+		{key: "the", proper: false},
+		{key: "i", proper: false},
+		{key: "saw", proper: false},
+		{key: "paris", proper: false}, // seen in lowercase too
+	}
+
+	for ntest, tt := range tests {
+		val, ok := words[tt.key]
+		if !ok {
+			t.Errorf("ntest: %d, no entry for %q", ntest, tt.key)
+			continue
+		}
+		if val.Proper != tt.proper {
+			const msg = "ntest: %d, %q proper: got %t, want %t\n"
+			t.Errorf(msg, ntest, tt.key, val.Proper, tt.proper)
+		}
+	}
+}
+
 func TestCountSpacesBetweenWords(t *testing.T) {
 	var tests = []struct {
 		input  string
@@ -152,3 +210,27 @@ func TestGetSelectedCharLen(t *testing.T) {
 		}
 	}
 }
+
+func TestPageLines(t *testing.T) {
+	type test struct {
+		height, top, lineHeight int
+		out                     int
+	}
+
+	tests := []test{
+		{height: 480, top: 20, lineHeight: 18, out: 26},
+		{height: 480, top: 20, lineHeight: 36, out: 13},
+		{height: 38, top: 20, lineHeight: 18, out: 1}, // the second line's descenders don't fit
+		{height: 42, top: 20, lineHeight: 18, out: 2},
+		{height: 10, top: 20, lineHeight: 18, out: 1},
+		{height: 480, top: 20, lineHeight: 0, out: 1},
+	}
+
+	for ntest, tt := range tests {
+		result := PageLines(tt.height, tt.top, tt.lineHeight)
+		if result != tt.out {
+			const msg = "ntest: %d, got: %d, want %d\n"
+			t.Errorf(msg, ntest, result, tt.out)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// the keys of vim mode, whatever isn't here does what it does outside of
+// it. gg and the count are in vimAction, they're more than one key.
+var defaultVimKeys = map[ActionKind][]string{
+	ActionVim:          {"v"},
+	ActionLineDown:     {"j"},
+	ActionLineUp:       {"k"},
+	ActionHalfPageDown: {"ctrl+d"},
+	ActionHalfPageUp:   {"ctrl+u"},
+	ActionBottom:       {"shift+g"},
+	ActionWordNext:     {"w"},
+	ActionWordPrev:     {"b"},
+	ActionLookup:       {"return"},
+	ActionIgnoreWord:   {"s"},
+	ActionSearch:       {"/"},
+}
+
+func DefaultVimKeymap() Keymap {
+	keys, err := NewKeymap(defaultVimKeys)
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}
+
+// vimAction is keyAction in vim mode: digits are the count for the next
+// key, g waits for another g
+func (app *App) vimAction(sym sdl.Keycode, mod uint16) ActionKind {
+	combo := NewKeyCombo(sym, mod)
+	pendingG := app.pendingG
+	app.pendingG = false
+
+	if combo.Mod == 0 && sym >= sdl.K_0 && sym <= sdl.K_9 && (sym != sdl.K_0 || app.count > 0) {
+		app.count = app.count*10 + int(sym-sdl.K_0)
+		return ActionNone
+	}
+	if combo == (KeyCombo{Sym: sdl.K_g}) {
+		if pendingG {
+			return ActionTop
+		}
+		app.pendingG = true
+		return ActionNone
+	}
+
+	kind, ok := app.vimKeys[combo]
+	if !ok {
+		kind = app.keys[combo]
+	}
+	if kind == ActionNone {
+		app.count = 0 // like vim, a key that does nothing forgets the count
+	}
+	return kind
+}
+
+// the count typed before the key that's done now, -1 when there was none
+func (app *App) takeCount() int {
+	n := app.count
+	app.count = 0
+	if n == 0 {
+		return -1
+	}
+	return n
+}
+
+func (app *App) vimHelp() []string {
+	lines := app.vimKeys.Help()
+	return append(lines,
+		fmt.Sprintf("%-18s %s", "top", "gg"),
+		fmt.Sprintf("%-18s %s", "count", "1-9 before a key, 10j"),
+	)
+}
+
+// cursorWord is the word the cursor is on, the line it's on and where the
+// word is in that line
+func (app *App) cursorWord() (int, Span, bool) {
+	if app.cursor < 0 {
+		return 0, Span{}, false
+	}
+	line := app.doc.LineAt(app.cursor)
+	at := app.cursor - app.doc.LineOffset(line)
+	for _, span := range app.doc.Words(line) {
+		if at >= span.Start && at < span.End {
+			return line, span, true
+		}
+	}
+	return line, Span{}, false
+}
+
+// the line at the top of the page once it stops scrolling, keys that
+// come in the same frame have to go by that one
+func (app *App) targetTop() int {
+	if app.jumpToLine >= 0 {
+		return int(app.scroll.clamp(float64(app.jumpToLine)))
+	}
+	return int(math.Round(app.scroll.Target()))
+}
+
+func (app *App) onPage(line int) bool {
+	top := app.targetTop()
+	return line >= top && line < top+app.numLines
+}
+
+// the first word from line on, or the last one from line back
+func (app *App) wordFrom(line int, forward bool) (int, bool) {
+	for ; line >= 0 && line < len(app.doc.Lines); line = nextLine(line, forward) {
+		words := app.doc.Words(line)
+		if len(words) == 0 {
+			continue
+		}
+		span := words[0]
+		if !forward {
+			span = words[len(words)-1]
+		}
+		return app.doc.LineOffset(line) + span.Start, true
+	}
+	return 0, false
+}
+
+func nextLine(line int, forward bool) int {
+	if forward {
+		return line + 1
+	}
+	return line - 1
+}
+
+// moveCursor goes n words forward (or back), a cursor that isn't on the
+// page starts from the first (or last) word on it. The page follows it.
+func (app *App) moveCursor(n int, forward bool) {
+	line, span, ok := app.cursorWord()
+	if !ok || !app.onPage(line) {
+		from := app.targetTop()
+		if !forward {
+			from += app.numLines - 1
+			if from >= len(app.doc.Lines) {
+				from = len(app.doc.Lines) - 1
+			}
+		}
+		if app.cursor, ok = app.wordFrom(from, forward); !ok {
+			app.cursor = -1
+			return
+		}
+		n--
+		line, span, _ = app.cursorWord()
+	}
+
+	for ; n > 0; n-- {
+		words := app.doc.Words(line)
+		i := 0
+		for i < len(words) && words[i] != span {
+			i++
+		}
+		if forward {
+			i++
+		} else {
+			i--
+		}
+		if i >= 0 && i < len(words) {
+			span = words[i]
+			continue
+		}
+		offset, ok := app.wordFrom(nextLine(line, forward), forward)
+		if !ok {
+			break // the first or the last word of the text
+		}
+		line = app.doc.LineAt(offset)
+		span = app.findSpan(line, offset)
+	}
+	app.cursor = app.doc.LineOffset(line) + span.Start
+
+	if top := app.targetTop(); line < top {
+		app.scroll.ScrollTo(float64(line))
+	} else if line >= top+app.numLines {
+		app.scroll.ScrollTo(float64(line - app.numLines + 1))
+	}
+}
+
+func (app *App) findSpan(line, offset int) Span {
+	at := offset - app.doc.LineOffset(line)
+	for _, span := range app.doc.Words(line) {
+		if span.Start == at {
+			return span
+		}
+	}
+	return Span{}
+}
+
+// goToLine is gg and G, the cursor goes to the first word of line
+func (app *App) goToLine(line int) {
+	if line < 0 {
+		line = 0
+	}
+	if line >= len(app.doc.Lines) {
+		line = len(app.doc.Lines) - 1
+	}
+	app.jumpToLine = line
+	if app.cursor >= 0 {
+		if offset, ok := app.wordFrom(line, true); ok {
+			app.cursor = offset
+		}
+	}
+}
+
+func (app *App) cursorText() (string, bool) {
+	line, span, ok := app.cursorWord()
+	if !ok {
+		return "", false
+	}
+	w := app.doc.Lines[line][span.Start:span.End]
+	return OmitTrailingPunctuation(OmitPrecedingPunctuation(w)), true
+}
+
+// cursorRect is where the cursor is on the screen, it's drawn around the
+// word like a search match
+func (app *App) cursorRect() (sdl.Rect, bool) {
+	line, span, ok := app.cursorWord()
+	if !ok || line < app.startIndex || line > app.startIndex+app.numLines {
+		return sdl.Rect{}, false
+	}
+	x0, x1, ok := app.doc.RangeX(line, span.Start, span.End, app.fam, PixelSize(app.ctx, app.fontSize))
+	if !ok {
+		return sdl.Rect{}, false
+	}
+	lineHeight := app.lineHeight()
+	baseline := app.pageTop + (line-app.startIndex)*lineHeight - app.scrollOffset
+	return sdl.Rect{X: int32(x0), Y: int32(baseline - lineHeight*4/5), W: int32(x1 - x0), H: int32(lineHeight)}, true
+}
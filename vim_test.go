@@ -0,0 +1,142 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// presses keys the way the keymap file writes them, "ctrl+d", "shift+g"
+func pressKeys(t *testing.T, app *App, keys ...string) {
+	t.Helper()
+	for _, k := range keys {
+		combo, err := ParseKeyCombo(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var mod uint16
+		if combo.Mod&ModCtrl != 0 {
+			mod |= sdl.KMOD_LCTRL
+		}
+		if combo.Mod&ModAlt != 0 {
+			mod |= sdl.KMOD_LALT
+		}
+		if combo.Mod&ModShift != 0 {
+			mod |= sdl.KMOD_LSHIFT
+		}
+		app.HandleEvent(keyUp(combo.Sym, mod))
+	}
+}
+
+func TestVimMotions(t *testing.T) {
+	const msg = "ntest: %d, got: %d, want %d\n"
+	tests := []struct {
+		keys string
+		want func(app *App) int
+	}{
+		{"v j j", func(app *App) int { return 2 }},
+		{"v 1 0 j", func(app *App) int { return 10 }},
+		{"v 1 0 j 4 k", func(app *App) int { return 6 }},
+		{"v ctrl+d", func(app *App) int { return app.numLines / 2 }},
+		{"v 2 ctrl+d ctrl+u", func(app *App) int { return app.numLines / 2 }},
+		{"v shift+g", func(app *App) int { return int(app.scroll.Max) }},
+		{"v shift+g g g", func(app *App) int { return 0 }},
+		{"v 3 shift+g", func(app *App) int { return 2 }},
+		{"v 4 g g", func(app *App) int { return 3 }},
+		// q does nothing and the count goes with it
+		{"v 5 q j", func(app *App) int { return 1 }},
+		// g and then something else isn't gg
+		{"v 9 j g j g", func(app *App) int { return 10 }},
+		// out of vim mode j is nothing and the digits are nothing
+		{"1 0 down", func(app *App) int { return 1 }},
+		{"v 2 right", func(app *App) int { return 2 * app.numLines }},
+	}
+
+	for i, test := range tests {
+		app, clock := testApp(t)
+		pressKeys(t, app, strings.Fields(test.keys)...)
+		settleApp(t, app, clock)
+
+		if want := test.want(app); app.startIndex != want {
+			t.Errorf(msg, i, app.startIndex, want)
+		}
+	}
+}
+
+func TestVimWordCursor(t *testing.T) {
+	const msg = "ntest: %d, got: %q, want %q\n"
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{"v", "It"},
+		{"v w", "was"},
+		{"v 4 w", "cold"},
+		{"v 4 w b", "bright"},
+		{"v b", "It"},
+		{"v w v v", "It"},
+		{"v 1 0 w", "clocks"},
+		{"v 1 0 w b", "the"},
+	}
+
+	for i, test := range tests {
+		app, clock := testApp(t)
+		pressKeys(t, app, strings.Fields(test.keys)...)
+		settleApp(t, app, clock)
+
+		if got, _ := app.cursorText(); got != test.want {
+			t.Errorf(msg, i, got, test.want)
+		}
+		if _, ok := app.cursorRect(); !ok {
+			t.Errorf("ntest: %d, the cursor isn't on the screen\n", i)
+		}
+	}
+}
+
+func TestVimCursorScrolls(t *testing.T) {
+	app, clock := testApp(t)
+	pressKeys(t, app, "v", "5", "0", "0", "w")
+	settleApp(t, app, clock)
+
+	line, _, ok := app.cursorWord()
+	if !ok || line < app.numLines || line < app.startIndex || line >= app.startIndex+app.numLines {
+		t.Errorf("got: line %d with the page at %d, want it on the page\n", line, app.startIndex)
+	}
+
+	// G takes the cursor along to the last line
+	pressKeys(t, app, "shift+g", "w")
+	settleApp(t, app, clock)
+	if line, _, _ = app.cursorWord(); line < app.startIndex || line >= app.startIndex+app.numLines {
+		t.Errorf("got: line %d with the page at %d, want it on the page\n", line, app.startIndex)
+	}
+}
+
+func TestVimLookup(t *testing.T) {
+	app, clock := testApp(t)
+	pressKeys(t, app, "v", "2", "w", "return")
+	settleApp(t, app, clock)
+	if app.selectedWord != "a" {
+		t.Errorf("got: %q, want %q\n", app.selectedWord, "a")
+	}
+
+	// a is ignored and then back to what it was
+	val, _ := DBView(app.db, WordKey(app.lem, "a"))
+	for _, want := range []string{"I", string(val[:1])} {
+		pressKeys(t, app, "s")
+		val, err := DBView(app.db, WordKey(app.lem, "a"))
+		if err != nil || !strings.HasPrefix(string(val), want+"_") {
+			t.Errorf("got: %q (%v), want %s_...\n", val, err, want)
+		}
+	}
+
+	// escape leaves vim mode first and quits after that
+	pressKeys(t, app, "escape")
+	if app.vim || !app.running || app.cursor >= 0 {
+		t.Errorf("escape didn't leave vim mode\n")
+	}
+	pressKeys(t, app, "escape")
+	if app.running {
+		t.Errorf("escape didn't quit\n")
+	}
+}